@@ -101,7 +101,7 @@ func data_util_html_swp() (*asset, error) {
 	return a, nil
 }
 
-var _data_index_html = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xb4\x54\x4b\x72\xe4\x20\x0c\xdd\xf7\x29\x28\xb2\xf6\xf8\x02\x6e\x6f\xe7\x00\x59\xcc\x5a\x06\xda\xd6\x14\x06\x17\xc8\xce\x74\xa5\x72\xf7\x01\xdb\x38\xd0\xe9\xa4\xab\xa6\x26\x2b\x7f\x9e\xa4\xa7\xf7\x24\x78\x7d\x25\x35\x4e\x1a\x48\x31\x3e\x28\x90\xca\xf1\xb7\xb7\xd3\xa9\x91\xb8\x30\xa1\xc1\xfb\x33\x37\xb0\x74\xe0\xd8\xf6\xa8\x2e\xf8\x47\xc9\x8a\xec\xc4\xdb\x13\x63\x1f\xe3\x2a\x34\x26\x14\x89\x60\x09\x0b\x6b\x08\x70\xc3\x56\x30\xc0\x90\xc0\xce\x81\x91\x0c\x04\xe1\xa2\x38\x1b\x9c\xba\x9c\xf9\x13\x6f\x7f\x82\xd6\xca\x5d\x9b\x1a\xda\x94\x32\xeb\x8c\x8f\xa7\xdf\x01\xd0\x98\x00\xe9\xec\x24\xed\x8b\xe1\x0c\xe5\x99\x13\x8e\xaa\x1a\x95\x99\xb3\xe0\x9c\x3a\x45\x07\x4d\x7d\xaf\x03\xbb\x04\x82\xfd\x23\xaf\xb5\x37\x75\xbf\x1c\x63\xbf\x06\x65\x8a\x1f\x4d\x77\x28\x07\xa7\x88\xb7\x4d\xdd\x15\x1d\xbc\x8b\xba\x11\x76\x74\xb4\xd2\xbc\x8b\x58\x05\x37\xf5\xac\x33\xd5\xb5\xc6\xc3\xce\xd2\x04\x5c\x30\x4c\xb3\x5a\x94\x23\x14\xa0\x63\x66\x8c\x4d\x79\xb1\xca\x5d\x4f\xd9\x34\x6b\x5d\x39\xec\x07\x2a\xed\xbd\xb1\x6f\xf3\xa3\x96\x57\x03\x23\x8a\xda\xeb\x40\xe7\x07\xfb\xc2\xdb\xe7\xf4\x5a\x48\xcc\xe9\xbf\x18\x97\x27\x20\xff\x1f\xe7\xf5\x49\x3d\xc6\x9e\x23\xf0\x2d\x13\x2b\x79\xa2\x73\x87\x5d\x4f\x9b\x48\x33\x8f\xd5\x84\xc2\x47\x0a\x68\x4b\x67\xbe\xcc\x81\x5e\x7d\x92\x74\x6f\x2f\x3e\xcc\xba\xa9\xc3\x5a\xac\xe7\x76\x7b\xd9\x1f\x0f\x8f\x7b\x67\x89\xec\xf8\xef\x27\x7e\x15\x10\x9b\x4f\x3b\xfc\xb8\x8d\xfc\xba\x88\xa3\x71\x07\xf9\x5a\x0b\x45\xd5\x6f\x97\x43\x5e\x6e\x8f\xba\xa9\xea\x85\xc3\x89\x98\x77\x22\xec\x6b\xdc\x87\xb0\xae\xe3\xf5\xc7\xef\xd5\xc9\x0d\x0c\x61\xf9\x4d\x78\xb1\x96\xb6\x9b\xf0\x6f\x00\x00\x00\xff\xff\xd7\x5d\x79\x96\x20\x05\x00\x00")
+var _data_index_html = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xb5\x54\xcb\x52\xe4\x30\x0c\xbc\xcf\x57\xb8\xcc\x39\xe4\x07\x32\xe1\x42\xd5\x7e\x00\x87\x3d\x2b\xb6\x27\x11\xe5\xd8\x29\x5b\x09\x0c\x53\xfc\x3b\x76\x3c\x09\xce\x30\xc0\x16\xc5\x9e\xf2\x68\xa9\xa5\x6e\xcb\x3a\x9d\x48\xf5\x83\x06\x52\x8c\x77\x0a\xa4\x72\xfc\xf5\x75\xb7\xab\x24\x4e\x4c\x68\xf0\x7e\xcf\x0d\x4c\x0d\x38\x96\x1e\xc5\x01\x9f\x95\x2c\xc8\x0e\xbc\xde\x31\xf6\x31\xae\x40\x63\x02\x49\x04\xb7\xb0\xb0\x86\x00\x13\x36\x83\x01\x86\x05\x6c\x1c\x18\xc9\x40\x10\x4e\x8a\xb3\xce\xa9\xc3\x9e\xdf\xf0\xfa\x0f\x68\xad\xdc\xb1\x2a\xa1\x5e\x52\x46\x9d\xd5\xe3\xcb\xef\x00\x68\x5c\x00\xe9\xec\x20\xed\x93\xe1\x0c\xe5\x9e\x13\xf6\xaa\xe8\x95\x19\xb3\xe0\xbc\xf4\x12\x1d\x34\xb5\xad\x0e\xd5\x25\x10\x9c\x3f\x72\xae\x73\x53\xd7\xe9\x18\xfb\xdb\x29\xb3\xf9\x51\x35\xab\x72\x70\x8a\x78\x5d\x95\xcd\xa6\x83\x77\x51\x17\xc2\xd6\x8e\xe6\x32\xef\x22\x66\xc1\x55\x39\xea\x4c\x75\xa9\x71\xb5\x73\x6b\x02\x4e\x18\x4e\xb3\x98\x94\x23\x14\xa0\x63\x66\x8c\x5d\xf2\x22\xcb\x55\x4f\xd9\x30\x6a\x5d\x38\x6c\x3b\xda\xda\x7b\x61\x5f\xf2\xa3\x94\x47\x03\x3d\x8a\xd2\xeb\x50\xce\x77\xf6\x89\xd7\x0f\xcb\xeb\x46\x62\x5e\xfe\x1f\xf8\xd4\xf3\x60\x1d\xdd\xbe\xe0\x70\xd7\x83\xc1\x83\xf2\xb4\x7f\xf4\xd6\xf0\xfa\x3e\x58\xa3\x2d\xc8\xaf\xd9\xaf\x0f\x83\x27\x20\xff\x8b\xd3\xf0\x09\x1f\x63\x0f\x11\xf8\x2f\xf3\xb0\xad\x13\x7d\x5c\xcd\xbb\x49\x22\xcd\xd8\x17\x03\x0a\x1f\x4b\x40\xbd\x75\xe6\xcb\x1c\x68\xd5\x27\x49\xd7\xa6\x2e\x47\xd2\x6d\x2f\xc3\xd0\xcd\x5b\x21\xbd\x9c\x1f\xdf\x2e\x93\xc6\x12\xd9\xfe\xe7\xfb\x64\x16\x10\x9b\x5f\x6e\xc8\xf7\x6d\xe4\xcb\x28\x1e\x8d\x5b\x8b\xcf\x5c\x28\x8a\x36\xad\x9e\x9c\xee\x1c\x75\xc1\xea\x85\xc3\x81\x98\x77\x22\x4c\x6f\x9c\x87\x30\xbc\xfd\xf1\xf6\x71\x76\x32\x81\x21\xec\x94\xed\xd9\x83\xb5\x94\xf6\xec\x1b\x6d\xd5\x81\x27\x7e\x05\x00\x00")
 
 func data_index_html_bytes() ([]byte, error) {
 	return bindata_read(
@@ -116,7 +116,7 @@ func data_index_html() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindata_file_info{name: "data/index.html", size: 1312, mode: os.FileMode(420), modTime: time.Unix(1426903785, 0)}
+	info := bindata_file_info{name: "data/index.html", size: 1406, mode: os.FileMode(420), modTime: time.Unix(1792357100, 0)}
 	a := &asset{bytes: bytes, info:  info}
 	return a, nil
 }
@@ -381,7 +381,7 @@ func data_static_my_js() (*asset, error) {
 	return a, nil
 }
 
-var _data_static_zoompic_js = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xb4\x54\x41\x6f\xdb\x3c\x0c\xbd\xeb\x57\xf0\xcb\x57\xa0\x12\x1a\xdb\x0d\x7a\x18\xd6\x2e\xbb\xec\xb4\xc3\xb6\x00\x3d\x0e\x3b\x28\x0e\x1d\xab\x93\x25\x43\x92\x93\x66\x43\xff\xfb\x28\xd9\x8e\x93\x2e\x3d\xee\x62\x59\xd4\x23\xf9\x48\x3e\x89\x55\x9d\x29\x83\xb2\x06\x7e\xe2\x61\x63\xf7\x86\xa3\x80\xdf\x0c\x40\x55\xc0\xff\x1b\xfe\x01\x10\x96\xb0\x57\x86\x00\x39\xee\xd0\x84\x07\xb2\xbe\x0c\x28\xcc\xf7\xb5\x2a\x6b\x58\x2e\x63\x0c\x9f\x6b\xac\xc2\xe8\x57\x76\xce\xad\x54\x09\xd9\x12\x16\xc9\x10\x1d\x46\xe3\x07\xb8\x1d\x71\x13\x72\x09\xb7\xc9\xf2\x92\xbe\x43\x4e\x6d\x4b\x19\x49\xe6\xb5\xc3\x8a\x20\xb3\x62\x73\x30\xb2\x51\x65\xf1\xcb\xda\xa6\x98\xc1\xcd\xe8\x9f\x07\xfb\x18\x9c\x32\x5b\x2e\x52\x00\x87\xa1\x73\x06\x2a\xa9\x3d\x46\xce\x80\xf4\x73\x91\xb6\x53\xdb\xfa\x2f\xde\x37\x97\x78\x7f\x5c\x82\xe9\x9a\x55\x6d\x83\xf5\x97\x0a\x38\x1e\x42\x36\x78\xff\xa3\x62\xd8\xd1\x14\x5c\x87\xec\x85\x4d\xd3\xdc\x62\x78\x0c\x32\x78\xde\x13\xbc\xca\xc9\xc0\xa7\x4c\x9e\xce\x8a\x56\x95\x3e\x6b\xd1\x65\xad\xdc\xe2\x6c\x0e\xa3\x33\xdf\xc8\x20\x45\x5f\x57\xc4\xac\xd0\xad\x08\x41\x54\x5b\xe9\x3c\x7e\x36\xa1\x47\x44\x0a\xe2\xad\xe0\xd4\x84\x2c\x3a\xbf\x11\x77\xea\xd1\x85\xa8\x14\x92\x6f\x6c\xd9\x35\xa4\x34\x91\x8f\xc2\x1c\x56\xc1\x12\xa2\x28\xa0\xac\xa5\x21\x5a\xa1\xee\x9a\xb5\x91\x4a\xc3\x56\x6a\x8d\xee\x00\xb1\x1e\x08\xb6\x5f\x6d\x95\xda\x49\xa1\x20\xf6\x97\x38\x41\xd7\x52\x87\xf0\x59\x05\x6a\xed\x18\x2c\xd4\xca\xc3\x1e\xd7\xd1\x67\xa0\xd0\xcf\x4b\xe4\x6b\x5a\xf9\xf5\x1a\x2b\xeb\xb0\x33\xda\xca\xcd\xf5\x49\x51\x62\x14\xc0\x55\x2e\x9f\xe4\x33\x1f\xb7\x00\x9d\xd3\xf7\x27\xd3\xf5\x18\x52\xa7\xd3\x84\xbf\xc8\x50\xe7\x0d\xc1\xd3\x4f\x89\x4a\x1f\xe5\x55\x9c\x36\x5d\xcc\x61\x21\x4e\x74\x30\x3f\x46\x97\xfe\x60\xca\xfb\xa3\x18\x92\xcc\xc4\x03\x9b\x56\x9a\xcd\x2b\x45\x7c\xea\x33\x0c\x9a\x40\x8d\x8d\x9f\xee\xf5\x99\x2c\x73\xdf\x6a\x15\x67\x3a\x13\x93\xc8\x6e\x78\x72\xf9\x9e\xbe\x74\xcd\xcd\x36\xd4\xd9\xe2\xc7\x79\x1a\x2f\x77\xf8\xd5\x06\xf4\x9c\xc2\xe2\xf3\xf0\x98\x98\x0d\x25\x4a\x86\x73\x4d\xc7\x89\xd3\xc9\x15\x9f\xfd\x4f\x45\x67\x26\x3a\xc6\xf6\x10\x54\xe4\x3b\xa9\x79\xaf\xaf\xd6\xfa\x33\x81\x51\x8e\x1e\x5b\x0c\xe0\x39\xf4\xda\x21\x26\x3b\xe9\x5e\xa9\xf6\x36\xd9\x4e\x15\xd7\x5b\xa6\x1b\x7b\xda\x9b\x74\x14\x5f\x84\x78\x93\x71\x0f\xdf\xd6\x4f\x58\x06\xb2\x1f\x1f\x37\x3a\xb8\x7b\xc7\xa6\x47\x23\xee\xdf\xf7\x7b\x52\x19\x3a\xda\x2f\xee\x18\x9b\xae\x20\x63\xec\x4f\x00\x00\x00\xff\xff\xac\x5c\xc5\xc6\x68\x05\x00\x00")
+var _data_static_zoompic_js = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xb5\x56\x4b\x8f\xdb\x36\x10\xbe\xeb\x57\x4c\x95\x45\x42\x61\x6d\xd9\x46\x9a\x76\xf3\x70\xf6\xd0\x5c\x72\x48\xbb\x40\x72\x0b\x72\xa0\xe5\x91\xc5\x44\x22\x05\x92\xb2\xbd\x09\xf6\xbf\x77\xf8\x90\x25\x3b\x76\xd1\x2d\xd0\x8b\x44\x0e\xe7\xf1\x71\xf8\xcd\x90\x49\xd9\xc9\xc2\x0a\x25\xe1\x1b\xde\xaf\xd5\x4e\x32\xcc\xe0\x47\x02\x20\x4a\x60\xbf\xc4\x31\x00\xc2\x12\x76\x42\x92\x42\x8e\x5b\x94\xf6\x35\x49\x1f\xa2\xd6\x15\xc3\xdc\x72\xbd\x41\x9b\xe5\xc2\xb0\xd4\xe2\x9e\xa6\xc8\x27\x20\x64\xdb\xd9\x34\xeb\x9d\x68\xb4\x9d\x96\x60\x75\x87\x23\x73\xcc\x77\x95\x28\x2a\x78\xbb\x74\x10\x4c\xfe\x1d\xb5\x82\xa7\x4f\xa1\x97\xbf\x19\xcb\xaf\xe1\xc5\xc1\x1b\xb7\xc8\x8a\x4e\xeb\x3b\x51\x4c\x06\x37\xd3\x41\x3b\xcb\xad\xfa\x68\xb5\x90\x1b\x96\x65\x63\x04\x25\xaf\x8d\x87\x00\x48\x83\xb3\x28\x64\xd7\x5c\x02\xd2\x2f\xfd\x6b\x2c\xd1\xe0\xbf\xc0\x59\xc6\x98\xed\xf9\x48\x69\x2b\x8a\x6f\xe9\x63\x9d\xed\x2f\x38\xd3\xf8\x15\x0b\xfb\x68\x77\xdd\x05\x77\x9d\x2c\x6b\xbe\x79\xb4\xbb\x1a\x4b\xdb\x7b\x8c\xce\x60\xba\x84\x85\x17\x38\x83\x5e\xf8\x06\xe6\xbd\xde\xa0\xb9\x84\xb9\x97\x3c\xf8\x6f\xa4\x6c\xad\x0a\xee\x38\x9e\x57\x1a\x4b\x52\x49\x67\xeb\x7b\xc9\x1b\x51\xcc\xbe\x2b\xd5\xcc\x52\x3a\xca\x68\x3f\x3a\xa2\x47\xc2\xd6\x62\x53\xfd\x84\xfb\xfa\x1c\x6e\xa2\x18\x31\xe2\xae\x52\x56\x99\x73\x1b\x38\x2c\x12\x7f\x16\xff\xe7\x66\x92\xe3\x92\x7c\x48\x92\x43\x33\xa0\x62\xfe\x68\xb9\x35\x2c\x00\xbc\xca\x49\xc0\x86\x48\x86\xd6\x66\xc4\x3d\x33\x6d\x51\x4f\x5b\xbe\xc1\x74\x02\xbd\x31\x5b\x73\xcb\xb3\xb0\x2f\xa7\x73\x87\xfa\x8e\x34\x08\x6a\xcb\xb5\xc1\xf7\xd2\x06\x0d\x07\x21\xbb\xe4\x9c\x92\x30\x75\xc6\x17\xfc\x0e\x39\x3a\xe3\x95\x5c\xb2\xb5\x2a\xba\x86\x1a\x55\x96\xf7\x7d\x2d\xfe\xb3\xc4\x6b\xcc\x66\x50\x54\x5c\x12\x2c\x5b\x75\xcd\x4a\x72\x51\xc3\x86\xd7\x35\xea\x7b\x70\xfb\x01\xab\xc2\x5f\x95\x3e\x9d\xe4\x0a\x5c\x7e\x09\x13\x74\x2d\x65\x08\xf7\xc2\x52\x6a\x7b\x67\xb6\x12\x06\x76\xb8\x72\x36\x11\x42\x38\xaf\x2c\x5f\xd1\x9f\x3d\x5b\x61\xa9\x34\x76\xb2\x56\x7c\xfd\x6c\xb4\xa9\xac\x27\xc0\x55\xce\xbf\xf2\x3d\xeb\xa7\x00\x9d\xae\x5f\x8d\x4e\xd7\xa0\xf5\x99\xf6\x27\xfc\x81\xdb\x2a\x6f\x48\xdd\x0f\x0a\x14\xf5\x81\x5e\xb3\x71\xd2\xb3\x09\x2c\xc6\x7d\x67\x72\xf0\xce\xcd\xbd\x2c\x5e\x1d\xc8\xe0\x69\x96\xbd\x4e\x86\x3f\x9d\xcd\x09\x23\xfe\x08\x11\x22\x27\xb0\xc6\xc6\x0c\xd7\xc2\x11\x2d\x73\xd3\xd6\xc2\x9d\xa9\xaf\xfe\x48\xb2\x6b\xe6\x4d\x3e\xfb\x2f\x95\xb9\xdc\xd8\x6a\xba\xf8\x72\x1c\xc6\xf0\x2d\xfe\xa9\x2c\x1a\x46\x6e\x31\x76\x2a\x1a\x52\x20\x2f\x38\xe6\xb4\x3b\x71\x5a\xb9\x62\xe9\x13\xda\xf4\x54\x3a\x43\x97\x1e\x52\xcd\xf2\x2d\xaf\x59\xe0\x57\xab\xcc\x11\xc1\x28\x46\xd0\x9d\x45\xe5\x09\x04\xee\x8c\x91\xf8\x6e\xe6\x63\x4e\x80\x5c\xf5\x85\x70\xe2\xcb\x69\xf5\x5e\x8e\xd0\x91\x28\xf5\x0b\x64\x7b\xca\xe1\x58\xf4\x3d\x6c\xf2\x41\x26\x29\x9d\x13\xdd\x9c\xe3\xda\x38\x82\xa3\xec\x08\xd0\x5a\xe8\x4b\x80\xbc\xde\x3f\x43\x22\xeb\x31\x03\x23\x1c\xd1\x6c\x62\x2e\x1d\xd1\xa7\x34\x8d\xbd\x9b\x46\x39\xb7\x56\xb3\xd4\xe8\x82\x0a\xf2\x78\x9e\xf5\x87\x7d\x9b\x66\x9f\xe7\x5f\x5c\x90\x5b\x17\x44\xe2\x0e\xde\x39\xc8\x99\xab\xef\x4f\xa2\xc1\x70\xed\x9d\xec\x6b\x4d\x74\xb0\xe8\x68\x35\x3e\x70\xf7\xf8\x28\x94\x2c\x85\x6e\x58\xfa\x41\x6d\x31\x14\x18\x65\x8b\xa8\xe4\x8b\xd3\x56\xf4\xd3\xdc\x54\xb7\xa7\x0f\x8c\xd8\xda\x7e\xca\x4c\x88\x74\x36\x33\x67\xb2\x31\xea\xc2\xe3\x26\xee\x2d\x2f\xb4\xf0\x8b\x0d\x3a\x0d\x65\x15\x2e\x8f\x4b\xda\x1a\x5d\x6b\x88\xad\xfa\xc1\x27\x2a\x2f\xa9\x2d\xb1\x03\xb4\x7d\xa5\xfb\x60\x9c\x1a\x15\xed\x2d\x6c\x09\x9c\x1a\xae\xa9\x59\xd0\xd6\x48\x89\x5c\x19\x6a\x51\x06\x3f\x11\x9f\x86\x94\x6f\xb9\x3e\xe9\xc7\x73\x2f\x1b\xf7\xd2\x20\x19\xee\xa2\x71\xd5\xfb\x25\x77\xd7\xb9\x3b\x8a\x0e\xf7\xaf\x95\x7b\x2e\x90\xfc\x70\x6d\xd3\xc2\xf3\xdf\x93\xe1\x3a\x74\xf3\x97\x61\x4e\xfd\x13\x35\xcd\x17\xcf\x93\xe1\x35\xb7\x84\x5f\x6f\x92\xa3\x37\xd5\x12\x5e\xfe\x16\x24\x2d\x8d\x6f\xe6\x61\xdc\xb9\xf1\x8b\x30\xde\xbb\xf1\x4d\x92\x0c\x37\x54\x92\x24\x7f\x03\xe6\xf3\xe0\x79\xc6\x0a\x00\x00")
 
 func data_static_zoompic_js_bytes() ([]byte, error) {
 	return bindata_read(
@@ -396,12 +396,12 @@ func data_static_zoompic_js() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindata_file_info{name: "data/static/zoompic.js", size: 1384, mode: os.FileMode(420), modTime: time.Unix(1426905649, 0)}
+	info := bindata_file_info{name: "data/static/zoompic.js", size: 2758, mode: os.FileMode(420), modTime: time.Unix(1792356845, 0)}
 	a := &asset{bytes: bytes, info:  info}
 	return a, nil
 }
 
-var _data_util_html = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x94\x54\x4f\x4f\xfc\x36\x10\xbd\xef\xa7\x70\xfd\x43\x15\x48\xdd\x18\xf5\xd0\x03\x64\x73\x01\x2a\x55\x6a\xe9\xaa\x45\xad\x38\x7a\xe3\x49\xe2\x25\xb1\x83\xed\x2c\x6c\xa3\xfd\xee\x1d\xc7\xf9\x07\x2c\xfc\xe0\x92\x38\xb6\x67\xe6\xcd\x7b\x2f\xd3\xb6\x02\x32\xa9\x80\xd0\x02\xb8\x00\x43\x0f\x87\x45\xfc\xc3\xf5\x9f\x57\x77\xf7\xeb\x1b\x52\xb8\xaa\x4c\x16\x71\x78\x11\x12\xfb\x3b\x7e\x81\x4b\x27\x5d\x09\xc9\x5f\xff\x5e\x91\x75\xa1\x9d\xb6\x31\x0b\x3b\xe1\xb4\x94\xea\x81\x14\x06\xb2\x15\x65\xd6\x71\x27\x53\xb6\xd1\xda\x59\x67\x78\xcd\x52\x6b\xa7\xaf\x08\xbf\x28\x31\x50\xae\xa8\x75\xfb\x12\x6c\x01\xe0\x28\xa9\x40\x48\x8e\x5b\xa9\x01\x50\xf4\xfd\xac\xd5\xfe\xcb\x09\xc2\xd5\x42\x1b\x97\x36\x8e\xc8\x54\x2b\xfa\x2a\x69\xc6\x77\x7e\x3b\xc2\x07\x25\xac\x8f\xc5\x4c\xb2\x76\xc4\x9a\x74\x45\x0b\xe7\xea\x0b\xc6\x52\x2d\x20\xda\x3e\x36\x60\x10\x85\xae\x58\x58\x2e\x4b\xee\xc0\xba\x68\x6b\x69\x12\xb3\x10\xd6\xb1\xc7\x06\xfa\xe2\x8d\x16\xfb\x64\xd1\xb6\xa0\x04\xf2\xbd\x98\x44\xc8\x90\x96\x20\xc2\x9b\x9a\x6f\x79\xdc\xce\x69\xac\xa4\x3a\x52\x31\x14\xc2\xca\x9d\x82\x47\x0a\xd6\x32\xcd\x8d\x14\x9d\xec\x4d\x49\xd2\x92\x5b\xbb\xa2\xae\x68\xaa\xcd\xd2\x1f\x90\xdc\xe8\xa6\xa6\x3e\xd6\x70\x95\x03\x39\x91\x4a\xc0\xf3\x4f\xe4\xa4\xf6\xb2\x93\x8b\x15\x89\x7c\x6c\x29\xbb\x82\x42\xee\x7a\xba\xf8\xc0\xa9\xd8\x2b\x5e\x21\xee\xff\x34\x32\xd4\xb6\x21\x2e\xfa\xcd\x67\x39\x1c\x7a\x61\x08\x69\x5b\x99\x11\x78\xec\xd3\x46\x37\xcf\x8e\xd0\xa8\xd2\x3b\x8a\x2f\x54\x63\x60\x84\x90\x7f\xa4\x00\x3d\x06\x41\x69\x61\x3c\x8a\x65\x95\x0f\x1d\xe0\x72\x89\xc8\xb1\x8a\xa0\x3d\x7f\x5d\x66\xd6\xb5\x36\xc3\x81\x7c\x50\xa2\x15\xca\xed\xe0\xd9\x55\xa0\x1a\x34\x07\xdf\xc1\x2d\xaf\xe0\xf4\xc5\xb5\xb3\x4b\xb4\x8e\x6b\x8c\x22\x19\xc7\xb2\x97\x33\xec\x81\xd6\x0e\x03\xe3\x3d\x01\x48\xc5\x00\x26\xe5\xb5\x93\x5a\x8d\x01\x71\x3d\x9c\xd4\x3c\x97\x8a\xfb\xc3\x65\x0a\x88\xc0\x20\xdc\x64\xac\x7a\x8d\x46\x3a\x1c\x62\x56\xf7\x29\x59\x4f\x6f\xbf\x88\x99\x67\x7d\xa8\x1e\xb3\xe6\x1d\x89\x79\x0e\x8a\xef\x3a\x89\x67\xa0\xa6\xd2\xe4\x28\x0a\xef\x87\xae\x18\x16\x19\xd5\xfc\x46\x89\x90\x96\x6f\x4a\x10\x89\x82\x27\x30\xbe\xdf\x80\xe3\xed\x4d\x64\xb5\x94\xe9\x43\x57\x0a\x7e\x95\xc6\xba\xd3\x33\x9a\xfc\xf8\xed\xfc\x97\xf3\xcb\xf0\xfc\x74\xf4\xda\xc0\x6e\x0a\x9e\x87\x4d\xbe\xf4\x9e\xcc\x6f\x9b\x6a\xf0\x64\x97\x92\x48\x81\x19\x72\x4f\xa9\x3f\xf3\x62\x0f\xfd\xe7\x7e\x1a\xd0\xd1\xae\xe3\x36\xa7\xef\x80\xb8\xd3\xa7\x53\x1e\x04\x33\x93\x31\xaf\x83\x6c\xe1\xcc\x2b\xd6\x1b\x61\x82\x39\x78\xe4\xc3\x3e\x6f\xd1\x83\x43\x9f\x3f\x7f\x9e\x9e\xdf\xb9\x9d\x85\x7d\x3f\x78\xd4\x50\x97\x62\xae\x61\xf0\x50\x6f\xaf\x23\x56\x72\xb2\x1a\xac\xf4\x82\xf7\x3d\x70\x73\x64\x14\x7c\xda\x6a\x9d\x04\xd3\xf8\x11\x46\xd7\x42\x3f\xa9\xe5\x13\xfe\xec\xd3\x5f\x83\x6a\xbe\xbe\xe0\x9b\xa7\xc9\xa4\xde\x78\xd0\x69\xfb\xa1\x8e\x1e\x73\xf4\xb7\xe3\xc6\xad\x71\xaf\x13\x74\xd8\xbd\xc7\x87\x57\x71\x22\x30\xfc\xe6\x7d\xc7\x5b\xec\xb8\xc2\x79\x51\xf8\x96\x43\xc4\x1f\xfe\xd3\x4e\x93\xe8\xab\x50\x49\x56\x6a\xee\x96\x25\x64\xee\x63\xd4\x5d\xdd\x68\x8e\x38\xec\xf8\x71\x75\x0c\xf2\x7c\x32\xf5\xff\xf3\xb1\xe1\x31\xbc\xff\x0f\x00\x00\xff\xff\x55\x10\x13\x79\x0f\x08\x00\x00")
+var _data_util_html = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x9d\x56\xdb\x6e\xdc\x36\x10\x7d\xf7\x57\xb0\x4c\x50\x38\x68\x57\x72\x9c\x36\x0f\xb6\x2c\xa0\xf0\x05\x30\x90\x38\x46\x63\xa4\x48\xdf\xb8\xe2\xac\x44\x5b\x12\x15\x92\x5a\x7b\x23\xec\xbf\x77\x78\xd1\x65\xbd\x6b\xd7\xc9\x8b\x24\x72\x38\xd7\x73\x66\xa8\xae\xe3\xb0\x10\x35\x10\x5a\x00\xe3\xa0\xe8\x7a\xbd\x97\xfc\x72\xf6\xe9\xf4\xe6\xeb\xf5\x39\x29\x4c\x55\xa6\x7b\x89\x7f\x11\x92\xd8\x33\xf6\x03\x3f\x8d\x30\x25\xa4\x7f\xff\x73\x4a\xae\x0b\x69\xa4\x4e\x62\xbf\xe3\xa5\xa5\xa8\xef\x48\xa1\x60\x71\x42\x63\x6d\x98\x11\x59\x3c\x97\xd2\x68\xa3\x58\x13\x67\x5a\x8f\xab\x08\x57\x94\x28\x28\x4f\xa8\x36\xab\x12\x74\x01\x60\x28\xa9\x80\x0b\x86\x5b\x99\x02\xa8\xe9\xd3\x56\xab\xd5\x0f\x1b\xf0\x47\x0b\xa9\x4c\xd6\x1a\x22\x32\x59\xd3\x47\x46\x17\x6c\x69\xb7\x23\x7c\x50\x12\x07\x5d\xb4\x24\x1a\x43\xb4\xca\x4e\x68\x61\x4c\x73\x14\xc7\x99\xe4\x10\xdd\x7e\x6b\x41\x61\x14\xb2\x8a\xfd\xe7\xac\x64\x06\xb4\x89\x6e\x35\x4d\x93\xd8\xab\xb9\xea\xc5\x7d\xf9\x92\xb9\xe4\xab\x74\xaf\xeb\xa0\xe6\x58\x6f\xfc\xe8\x41\x58\x60\x59\x3c\x08\x5b\x3e\xb7\xeb\x78\x3b\x2d\x63\x25\xea\x1d\x1e\xbd\x23\xf4\xec\x10\xdc\xe1\xb0\x11\x59\xae\x04\x77\xb0\xb7\x25\xc9\x4a\xa6\xf5\x09\x35\x45\x5b\xcd\x67\x56\x40\x72\x25\xdb\x86\x5a\x5d\xc5\xea\x1c\xc8\x6b\x51\x73\x78\xf8\x9d\xbc\x6e\x2c\xec\xe4\xe8\x84\x44\x56\xb7\x14\xce\x21\x17\xcb\x50\x2e\xd6\xd7\x94\xaf\x6a\x56\x61\xdc\xdf\x25\x56\xa8\xeb\xbc\x5e\x74\x69\xad\xac\xd7\x01\x18\x42\xba\x4e\x2c\x08\x7c\x0b\x66\xa3\xf3\x07\x43\x68\x54\xc9\x25\xc5\x17\xa2\xd1\x57\x84\x90\x2f\x82\x83\x1c\x94\xa0\xd4\x30\x88\x12\x51\xe5\x7d\x06\xf8\x39\xc3\xc8\xd1\x0b\xa7\xa1\x7e\xce\x72\xec\x52\x9b\xc4\x81\xf5\xa0\x44\xd6\x08\xb7\x81\x07\x53\x41\xdd\x22\x39\xd8\x12\xae\x58\x05\xfb\x1b\xc7\xde\x1c\x23\x75\x4c\xab\x6a\xb2\x60\xe8\xf6\x78\x12\xbb\x2f\xab\x8b\x21\x66\xa1\x00\x58\x8a\x3e\x98\x8c\x35\x46\xc8\x7a\x50\x48\x9a\x5e\xd2\xb0\x5c\xd4\xcc\x0a\x67\x19\x60\x04\x0a\xc3\x4d\x07\xaf\x67\x48\xa4\xf5\xda\x95\x26\xec\x7c\x84\x6a\x0e\x4a\xaf\xd7\xc8\x8d\x86\xd5\xbd\x99\x39\xe3\x39\x50\xe2\x3a\xd0\xb6\x01\xcb\xee\x88\x5c\x90\xc1\xd0\x67\xbb\xf3\x59\x7c\x47\x6b\x04\x11\x47\x9a\xfc\xd6\x75\x25\xd4\x5b\x66\x91\x3d\x68\x36\x0d\x19\x25\x71\x13\x92\x89\x03\xb0\xe1\x23\x89\x2d\xde\x7d\xde\x49\xdc\x3e\x41\x2e\x96\x43\xcd\x96\x8e\x5c\x93\x72\x8c\x49\x93\x9d\xf9\x5b\x26\x3a\x67\xe8\x64\xe0\xd1\x2b\x4a\xb8\xd0\x6c\x5e\x02\x4f\x6b\xb8\x07\x65\x2b\xed\xe3\xd8\x3e\x89\x78\x96\x22\xbb\x73\xae\xe0\x42\x28\x6d\xf6\xdf\xd0\xf4\xd7\x57\x07\xef\x0f\x8e\xfd\xf3\xc5\xda\xd7\x0a\x96\xa3\xf2\x54\x6d\xec\x08\xdb\x0d\xf9\x55\x5b\xf5\xdd\xe0\x4c\x12\xc1\xd1\x42\x6e\x31\xb0\x32\x4b\xb3\x3e\xff\xdc\xce\x21\x3a\x34\xca\xb0\xcd\xe8\x13\x41\xdc\xc8\xfd\xd1\x0e\x06\x33\x21\x50\xde\x78\xc2\x78\x99\x45\x2c\x50\x70\x0c\xb3\x67\xe7\xb3\x79\x5e\x21\xfb\xfb\x3c\x0f\x5f\x5e\x9e\x0f\x4c\x4f\xd4\xfe\x5f\x79\xc0\x50\x96\x7c\x8a\xa1\xe7\x50\xa0\xd7\x0e\x2a\x19\x51\xf5\x54\xda\xa8\xfb\x0a\x98\xda\x31\x84\x5e\x4c\x35\x07\xc1\x38\xf8\xb8\x92\x0d\x97\xf7\xf5\xec\x1e\xc7\xcc\xd8\xaf\x88\xe6\xe3\x03\x36\x79\x9a\x8e\xe8\x0d\x02\x87\xed\xb3\x38\xda\x98\x6d\x4b\x2a\x73\x8d\x7b\x0e\xd0\x7e\xf7\x2b\x3e\x2c\x8a\x63\x01\xfd\x80\x09\x19\xdf\x62\xc6\x15\x4e\xaa\xc2\xa6\xec\x35\x3e\xda\xa5\x1e\x67\xe0\x8f\x86\x4a\x16\xa5\x64\x66\x56\xc2\xc2\x3c\x1f\xb5\xf3\x1b\x4d\x23\xf6\x3b\x76\x50\xee\x0a\x79\x3a\x13\x43\x3f\xef\x1a\x1e\x3b\xb0\xb6\x17\x5d\xab\x3d\xd4\x06\xaa\xc6\xde\xa7\x1b\xff\x27\xd3\xd1\x8a\x31\x30\xd4\x52\xd4\xff\xa0\xbc\x4b\x3f\x88\xb9\x62\x6a\x45\xbe\xb0\x52\x70\x87\x37\x5e\x7f\xef\x9c\xd4\x58\xea\x0d\x77\x9c\x5b\xb8\xe7\x0c\xad\x70\xa8\xf5\xc8\x08\xa3\xd2\xc4\xf0\xf4\x42\xe0\xff\x04\xfe\xd9\x70\xb7\xea\xba\xe8\x46\x1a\x56\xda\x6c\xed\x56\x8c\xa7\x36\x8e\x5f\xc1\x12\x14\x59\x7a\xc7\xc0\xa7\x8a\x4e\xb4\xa5\xe8\x46\x7b\xf4\x97\x2d\xe9\x60\x43\x9a\xd1\x02\x11\x35\x9e\xf1\x07\x08\x67\xab\x8d\x58\xce\x5a\x98\x1a\xdc\xa8\x78\x30\xf6\x09\x9b\x4c\x0f\xf6\x5c\x29\x06\x7d\xeb\xd9\xcb\xa3\x4b\xfd\x2f\x28\xb9\x5e\xcf\xfa\x0b\x15\xad\x07\xd1\x85\x54\x15\xc3\xbb\xf8\xf0\xe0\xe0\x7d\xfc\x36\x3e\x24\x6f\xff\x3c\x3a\xf8\x83\xda\x23\xe1\x8a\xd8\x59\x09\x3b\x16\xc8\x1c\x2f\x9d\xdc\x5d\xc2\xd8\x7c\x5a\x3f\xf2\x6d\x8f\xec\xf4\xec\x04\x3f\xe9\xf7\x82\x89\xb2\x55\x1b\x98\xd9\x6b\x2e\xb2\xfb\xf0\x58\x0d\xdf\x16\x7d\x3f\x24\x6d\x48\xfd\xa9\x9f\xe0\x4a\x91\x5e\x5a\xbc\x0b\xf7\x69\x5b\x62\x58\x9c\x16\x90\xdd\xc1\x28\x3c\x57\x4a\x2a\xbf\x9a\xf0\xc0\x37\xf8\x34\x02\x67\xb9\x0f\x00\xac\x12\xed\x91\xbf\x1c\x32\x09\xe4\x0a\x2d\x38\xee\x04\xa7\x4f\x97\x71\xeb\x30\x86\xb5\x83\x9e\xc3\xd5\x31\x2d\x55\x7f\xeb\x87\x59\x3d\xb6\xe8\xf8\xf7\xda\x1f\xfa\x0f\xa1\x12\xc8\x5c\x5f\x0c\x00\x00")
 
 func data_util_html_bytes() ([]byte, error) {
 	return bindata_read(
@@ -416,12 +416,12 @@ func data_util_html() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindata_file_info{name: "data/util.html", size: 2063, mode: os.FileMode(420), modTime: time.Unix(1450924933, 0)}
+	info := bindata_file_info{name: "data/util.html", size: 3167, mode: os.FileMode(420), modTime: time.Unix(1792358715, 0)}
 	a := &asset{bytes: bytes, info:  info}
 	return a, nil
 }
 
-var _data_zoompic_html = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x8c\x53\xc1\x92\xd3\x3a\x10\xbc\xe7\x2b\xa6\xf4\x2e\x8f\x83\x63\xb8\x2e\x8e\x4f\x50\x14\x17\x38\xb0\x3f\x30\xb1\x26\xc9\xb0\xb2\x64\xa4\x89\x09\xb8\xfc\xef\x8c\xe2\xc4\xeb\x2c\x29\xc0\x17\x5b\x23\x4d\xb7\xba\xa7\x3d\x0c\x42\x6d\xe7\x50\x08\xcc\x81\xd0\x52\x34\xe3\xb8\x5a\x55\x96\x7b\x68\x1c\xa6\xb4\x31\x31\x7c\x37\x90\xe4\x87\xa3\x8d\x11\x3a\x49\x81\x8e\xf7\xfe\x01\x1a\xf2\x42\xf1\xad\xa9\x57\x00\xc3\xc0\x3b\xa0\x6f\xb0\x7e\x7f\x12\x30\xeb\x36\xf4\x46\x5f\xd8\x73\x46\x03\xa8\x7a\xb6\x14\xae\x80\x3f\x43\x68\x0b\xad\x28\x6a\x6c\x36\xa6\xec\x0e\x41\x42\x19\x22\xef\xcb\x61\x58\x7f\xb4\xe3\x68\xa0\x09\x5e\x62\x70\xa9\xae\xca\x73\xef\x44\x42\x2e\xd1\x04\x88\x70\x88\xb4\xd3\xe6\x33\x7d\x7e\x2a\x6e\xf7\x37\x0c\xba\x36\x60\x51\xb0\xb0\x9c\x5a\xce\xf5\x36\x58\x74\xb7\xb4\x72\x38\xb6\xdb\x99\x37\x83\x55\x25\x5e\xd8\xbc\x96\x56\x55\xa9\x5e\xd4\xb7\x96\x78\xec\xb7\x18\x61\x7a\x15\x3b\x3e\x91\x2d\xb6\x41\x24\xb4\x13\xc2\x6f\x47\x0b\xf6\x5e\xad\x9d\xee\xba\xdc\xce\x3a\x91\x9f\xf7\x74\xf7\xe8\x16\xbd\x73\x59\x37\x1c\xd7\x55\x1e\x00\x46\x42\x60\xbb\x31\x1d\x37\x85\x0f\x42\x29\x5f\xdf\x5b\x3a\x65\x05\xfa\xfd\x81\xe4\x53\x2e\x8f\x63\x55\x5e\x1b\xd4\x48\xed\xbf\x05\xcb\xc2\x66\x27\xff\x33\x57\xda\xad\xf8\x79\xe0\x2d\xc6\x3d\xfb\xc2\xd1\x4e\x1e\xe0\xcd\xeb\xee\x64\x20\xf8\xc6\x71\xf3\xb4\x31\x09\x7b\x3a\xf3\xfc\xff\xcc\xff\xca\xd4\x5f\xb4\x0c\xe7\x7a\x76\x72\xb2\x6f\x49\x5e\x95\x47\x77\x5f\x2c\x74\x47\xe7\x0a\xcd\xc1\x41\x5e\xea\x5e\xdc\x52\xa7\x89\x5b\x47\xb6\x7e\xc4\x27\xf2\x3a\xa7\xf5\x3b\x8d\x6f\xd6\xfa\x27\x91\x4b\x6d\x97\xe8\xdc\xc9\x5d\xfd\x59\x57\xec\xd1\xdd\xbf\xfa\x3f\x42\xbe\xc8\xd4\x63\x5e\xfe\xcd\x8b\x4b\xcc\xe6\x8f\x39\x76\xa9\x89\xdc\xc9\x25\xb3\x49\x50\xb8\x29\x73\xbe\x75\xf8\xeb\xaf\xc9\x28\xdc\x74\x42\xcf\x2e\x7f\xe6\x5d\xd0\x09\x9c\x7f\xe6\x5f\x01\x00\x00\xff\xff\x6a\x15\xe6\x5e\xe3\x03\x00\x00")
+var _data_zoompic_html = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xad\x56\x6d\x6f\xd3\x30\x10\xfe\xde\x5f\x71\xf2\x26\x5e\xa4\x26\xe9\x06\x43\x53\x96\x56\x42\xe2\x45\x20\x31\x10\x20\xbe\xbb\xb1\xdb\x9a\x39\x76\x71\xdc\xac\x50\xe5\xbf\x73\x76\x9c\xb4\x19\x2d\x1d\x2f\x95\xaa\xd8\x67\xdf\x8b\x9f\xe7\xee\xec\xcd\xc6\xf2\x62\x29\xa9\xe5\x40\x16\x9c\x32\x6e\x48\x5d\x0f\x06\x19\x13\x15\xe4\x92\x96\xe5\x98\x18\x7d\x4b\xa0\xb4\xdf\x25\x1f\x13\xcb\xd7\x36\xa2\x52\xcc\x55\x0a\x39\x57\x96\x9b\x2b\x32\x19\x00\x6c\x36\x62\x06\xfc\x1b\xc4\x2f\xd7\x16\x48\x5c\xe8\x8a\xe0\x87\x56\xc2\x59\x03\xc8\x2a\xc1\xb8\x6e\x0d\xfe\xd0\xba\x88\x50\x82\x56\x4d\x3e\x26\xc9\x72\xa1\xad\x4e\xb4\x11\xf3\x64\xb3\x89\xdf\xb0\xba\x26\x90\x6b\x65\x8d\x96\xe5\x24\x4b\xbc\x6e\xe3\x84\xcb\x92\x37\x06\x29\x2c\x0c\x9f\xa1\xb2\x77\xef\x7e\x99\x28\xe6\x3d\x0f\x38\x27\x20\xd8\xee\x8c\x51\x4b\x23\x26\xca\x42\xb8\x5d\x85\x66\x54\xf6\x83\xb0\x8b\x55\x31\xed\xa2\x70\xa6\xb3\x84\x06\xdf\x0a\x45\x83\x2c\x41\x64\x26\x83\xc1\x66\x73\x2a\x18\xa4\x63\xf0\x3b\x71\x7a\x2b\xec\x02\xe2\x2f\xdc\x94\x42\xab\xb2\xae\x3d\x22\x73\x0b\x8f\x24\x57\x10\x3f\x86\x33\xa7\xdc\x47\x15\xaa\xb0\xfb\x28\xbc\xad\xd9\xd4\x47\x62\xa8\x9a\x73\x38\xad\xbc\xfb\x3b\x70\xf4\xb0\x3c\xad\x02\x9a\x56\x58\x67\xde\xc7\x84\xc2\x0f\xd4\xa0\xf1\xba\x0e\xfe\x5b\x5c\x9d\x96\x50\x54\x86\xb3\xa6\xe0\x2d\x5c\xd3\x02\xd7\x86\x40\x19\xe3\xac\x11\x3d\x77\xc3\xf8\x95\x36\x05\x45\xb6\xdf\x52\x05\xe7\x43\x38\x1f\x8d\x9e\xc1\xd9\x45\x3a\x7a\x4a\x02\x74\x7d\x4e\x70\x18\x19\xbd\x52\xa8\xda\x9d\x77\xc1\xc5\x7c\x61\x53\xb8\x1c\x2d\xd7\x57\x6d\x0e\xf9\xa8\x01\xe1\xad\x6b\x98\x6a\x83\x29\x99\xc2\xf9\x72\x0d\xa5\x96\x08\xf9\xc9\xe8\x32\xbf\x0a\x11\xee\xe7\xae\x3d\xf5\x61\xf6\xc2\xb4\x95\x0e\xbc\xe7\xf8\x1d\x2f\xa6\x08\xc8\x1e\x9e\x4a\x4b\xf3\x9b\xa8\x68\xd6\xef\x51\x0b\x0d\x41\x3b\x06\x0f\x32\x74\x97\x9f\x80\x76\x80\xef\x48\x55\xb9\x0d\x41\x21\xcc\xba\xfa\xf8\x43\xe8\x8f\x15\xc1\x16\xc1\x23\x90\xf6\x3b\x87\xa2\xd5\x94\x1a\x68\x3e\xd1\x4c\xac\x39\x8b\xa6\xda\x5a\x5d\x34\xe4\xfc\xb2\x35\x12\x4a\x61\x07\x0a\xc9\xb3\xb3\xec\xda\x01\x15\xdb\x35\x5c\x5d\xc9\x1d\xdd\x4e\x8c\x0b\x52\x4c\x32\xc7\x0d\x26\x39\xf5\xf5\xbf\x14\x79\xa4\xb4\xe5\xa5\x3b\x12\x82\xb0\x76\xa7\xc2\xf1\x6b\x6e\xaf\x9d\xb8\xae\xb3\xa4\x55\xc0\x7e\x83\xfa\x7d\x63\xee\x80\x1d\x7f\x27\xa4\x75\x3b\xb5\xaa\x43\xb3\xa0\x06\x4b\x27\x92\x7c\x86\x90\x9e\x21\xa4\x04\xb4\xca\xa5\xc8\x6f\xc6\xa4\xa4\x15\xf7\x7e\x1e\x6d\xfd\x3f\x26\x93\x4f\x28\x06\x2f\x77\x88\x36\x30\xf6\x9d\x7b\xfa\x31\x70\x88\xaf\xf5\x4b\x26\x6c\x60\xf6\x7f\xc5\x65\xb4\xc5\xb6\xbf\x13\xd4\x10\x1e\xba\x8d\x0f\x31\xb8\x07\x27\x97\xcf\x9e\x3c\xbd\x3a\x14\xd9\x51\xf7\xbf\x75\x62\x5c\xe6\x75\x5e\x2e\xfe\xd6\x0b\xe0\x3f\x62\xae\xd4\xcc\xbd\xce\xcb\xb8\xe4\x96\x7f\x10\x79\x9f\x87\x17\x5e\x7c\x98\x83\x36\xef\x7d\x44\xc9\x4a\xee\x4f\x40\x58\xae\xa4\x8c\xfc\xc9\xee\xe6\xe2\x4e\xf0\x6d\x36\x1a\x6a\x85\x9a\x77\x75\x3f\x8a\x2e\x52\xd7\x64\x4c\x39\x84\x65\x0a\xb8\xe3\x66\x08\xeb\x14\x0c\xff\xca\x73\x3b\x84\x55\x0a\x2b\x35\x93\xd4\xdd\x60\xa2\xa4\x53\xc9\x99\xcb\xdf\x8f\xde\x8a\xcb\xde\xbd\x69\xbb\xe3\xb7\xd3\xfa\x4c\x6f\xf0\x3a\x42\xdd\x17\xc8\xca\x41\xcd\x80\xf9\x2e\xa1\xbd\xe6\x85\xb7\x07\x76\xe5\x6d\x87\x70\xc9\xc9\xd9\x7d\x68\x3c\x68\xb2\xd7\x0f\x27\xef\xc3\x4d\xf4\x4f\x26\xef\xb4\xb1\xcf\x6e\xba\xdf\xe0\x96\xd6\xd0\xcd\xba\x41\x7b\xdd\x67\x65\x6e\xc4\xd2\x86\x36\x89\x54\x59\x91\x27\xee\x5d\x81\x5c\xc5\x5f\x4b\x82\xe6\x9a\x1d\xfe\x69\xb0\x7d\x52\xcd\x34\x16\xb8\x7f\x52\xfd\x04\x47\xb3\x3a\xad\x69\x09\x00\x00")
 
 func data_zoompic_html_bytes() ([]byte, error) {
 	return bindata_read(
@@ -436,7 +436,27 @@ func data_zoompic_html() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindata_file_info{name: "data/zoompic.html", size: 995, mode: os.FileMode(420), modTime: time.Unix(1426903785, 0)}
+	info := bindata_file_info{name: "data/zoompic.html", size: 2409, mode: os.FileMode(420), modTime: time.Unix(1792360809, 0)}
+	a := &asset{bytes: bytes, info:  info}
+	return a, nil
+}
+
+var _data_publish_html = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xa5\x55\xc1\x72\x9c\x30\x0c\xbd\xef\x57\xb8\xa4\xd3\x1b\x90\xf6\xd0\x43\x43\xf6\xd2\xf4\xd0\x43\xd3\x4c\x9b\x99\x4e\x8e\x06\xb4\xe0\xd6\x18\x6a\xcc\x36\x29\xc3\xbf\x57\xc6\x36\x18\x42\x26\x9b\xe9\x65\x01\x5b\x7a\x92\xde\x93\xb4\x7d\x9f\xc3\x81\x09\x20\x41\xd3\xa5\x25\xd0\x1c\x64\x30\x0c\xbb\xe4\xd5\xd5\xd7\x8f\xb7\x77\x37\x9f\x48\xa9\x2a\xbe\xdf\x25\xe6\x41\x48\xa2\x6d\xf4\x0b\xbe\x2a\xa6\x38\xec\xfb\x3e\xba\xd5\x2f\xc3\x90\xc4\xe6\xc4\xdc\x72\x26\x7e\x91\x52\xc2\xe1\x32\x40\x93\x6f\x75\xad\x86\xa1\x55\x54\xb1\x2c\x4e\xf1\xa3\x55\x92\x36\x51\xc5\x44\x94\xb5\x6d\x40\x24\xf0\xcb\xa0\x55\x0f\x1c\xda\x12\x40\x05\xa4\x82\x9c\x51\x3c\xca\x24\x80\x08\x9e\xc7\xac\x1e\x5e\x0c\x64\x4c\xcb\x5a\xaa\xac\x53\x84\x65\xb5\x08\x9e\x00\x3f\xd0\xa3\xbe\x8e\xf0\x27\x20\xf1\xc8\x43\xec\x88\x48\xd2\x3a\x7f\xd8\xef\xfa\x1e\x44\x8e\xcc\xe1\x8b\xc7\xe8\x01\x51\x0c\xa3\xda\xc5\x58\xa2\xeb\x48\xe6\xb6\x47\x21\x59\xae\xed\xfb\x5e\x41\xd5\x70\xaa\x16\xd2\x90\x48\x3b\x24\x39\x3b\x92\x8c\xd3\xb6\xbd\x0c\x04\x3d\xa6\x54\x12\xf3\x08\x0f\xec\x1e\xf2\x50\xd5\xcd\x58\xe8\x63\xbb\x90\x09\x81\x30\x96\x05\xef\x1a\xab\x53\x94\xcd\x77\x78\x4b\xdd\x5d\x2a\xa9\xc8\x09\xcd\x14\x3b\x82\x63\x88\x89\x1c\xee\x23\x5d\x48\xb0\xe8\x00\x3a\xb9\x77\xdc\x0b\x4d\x9a\x8e\xf3\x50\xb2\xa2\x54\x53\x80\x51\x86\x3d\x86\x31\x88\x67\x8a\x55\x10\xa2\x6d\xb0\xff\x51\x82\xd0\x50\x49\x8c\x06\x0e\x2f\xee\xb8\x4d\x3b\xc6\xbc\x8d\x06\xe3\x8b\x7d\xec\x9e\x2e\x27\x49\xe5\xf8\x98\x53\x52\x65\x57\xa5\xa1\xe6\x9a\x14\xb2\xee\x0c\x5d\x7d\x8f\x85\x16\x40\xa2\x9b\xb2\x56\x75\x6b\x44\x73\x19\x24\x36\xa8\xa5\xc6\xe4\xdc\x8c\x86\x31\x12\xf0\x19\x95\xb4\x74\xcc\xe5\xb1\xaa\x70\x01\xf1\x35\xc4\x40\xc8\x5a\x1e\x90\x56\x66\x36\x85\xd9\xf7\x67\x53\xcc\xd4\x7b\x34\xfa\x45\xd1\x46\xb1\x5a\xf8\x11\x1a\x77\xd7\xd0\x82\x09\xaa\xaf\xc3\x0c\x04\xf6\x1c\xc6\xd1\xc2\x5c\x61\x03\x69\x5d\x9a\x19\x7b\x2a\xc4\x23\xd2\x54\xe9\x3a\xd2\xb1\xbd\x6a\xa1\x39\x06\xd9\x0c\x67\x28\x36\xd8\x7d\xcf\x0e\xa4\x50\xc8\x25\x45\x46\xdf\x62\x0a\xbe\xd6\x7d\xdf\xd8\xe3\x60\xff\xe6\xec\xfc\xfd\xf9\x85\xf9\x9d\x44\xdf\xb2\x8e\x6e\x24\x1c\x67\x8f\xc9\x76\xce\xda\x97\x10\x3d\x5a\x7b\x88\x60\x63\x3a\xf0\x9b\x44\xe4\xf5\x78\x35\x0c\xae\x28\xdb\xd6\x16\xe4\x71\x50\x1d\x10\x79\x34\xbd\x3d\x37\xe4\x32\x28\x82\x73\x57\x6b\x74\xdd\x55\x36\xfa\x66\x15\xd7\x70\xaf\x5c\x15\xef\x9e\xa9\x78\x86\x72\xf6\x2b\xaf\xb5\x62\x93\xa8\x4e\x3a\x96\x63\xa7\x4d\x83\xe5\x37\xf9\x1d\x50\x69\x7b\xfc\x65\x1a\x7b\x2a\xaf\x46\xd8\xa5\xfd\x5d\x51\xa9\x0c\xcd\x23\x79\x3a\xd4\x9a\x40\x2f\x93\x2f\x38\xac\xa5\x13\xeb\x09\x48\x0f\xed\x9a\x56\xb0\x85\x36\x0b\xb2\xe2\x62\xd9\xd8\x8b\x9d\xb1\xda\xb2\xf3\xba\xde\x5e\xce\xe3\xbc\xbf\x64\x3b\xcb\xfa\x0f\x8e\xbb\xfe\x37\x42\x19\x50\xf8\x90\x72\x56\x88\x0f\xc4\x10\x7a\x61\x66\xc6\xd5\x1a\x45\xf1\xba\xdc\xdd\x7a\x8f\xfc\xad\xeb\x2a\xc4\x6f\xbb\x44\xd0\x05\x3f\x1e\x2d\x91\x71\x81\x9c\xb0\x17\xff\x67\xb8\xed\x38\xae\xd4\xb2\xa7\x76\x15\x9a\x49\x25\x02\xfe\x80\xdc\x9c\xd7\x85\xf7\x46\xfd\xde\x06\x5b\x4e\x9f\x4e\xc0\x4c\xd2\x3a\x01\x73\x6a\x13\xa8\x39\xea\x42\x4e\x1d\x9a\x09\x18\xbb\x40\x8f\xef\xb3\xdb\xd5\x19\xe2\x7a\x9d\x41\x0d\xc6\x2d\x2d\x4e\x81\xb0\x23\x60\xad\xdb\x86\x0a\xe7\xc0\x69\x0a\x7c\xda\x3d\xfa\x66\xef\xfa\xd8\x8f\x77\x72\x27\xff\x03\x11\x63\x3e\x54\xea\x09\x00\x00")
+
+func data_publish_html_bytes() ([]byte, error) {
+	return bindata_read(
+		_data_publish_html,
+		"data/publish.html",
+	)
+}
+
+func data_publish_html() (*asset, error) {
+	bytes, err := data_publish_html_bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindata_file_info{name: "data/publish.html", size: 2538, mode: os.FileMode(420), modTime: time.Unix(1792357159, 0)}
 	a := &asset{bytes: bytes, info:  info}
 	return a, nil
 }
@@ -496,6 +516,7 @@ var _bindata = map[string]func() (*asset, error){
 	"data/.index.html.swp": data_index_html_swp,
 	"data/.util.html.swp": data_util_html_swp,
 	"data/index.html": data_index_html,
+	"data/publish.html": data_publish_html,
 	"data/slideshow.html": data_slideshow_html,
 	"data/static/.my.js.swp": data_static_my_js_swp,
 	"data/static/bootstrap/css/bootstrap-responsive.css": data_static_bootstrap_css_bootstrap_responsive_css,
@@ -561,6 +582,8 @@ var _bintree = &_bintree_t{nil, map[string]*_bintree_t{
 		}},
 		"index.html": &_bintree_t{data_index_html, map[string]*_bintree_t{
 		}},
+		"publish.html": &_bintree_t{data_publish_html, map[string]*_bintree_t{
+		}},
 		"slideshow.html": &_bintree_t{data_slideshow_html, map[string]*_bintree_t{
 		}},
 		"static": &_bintree_t{nil, map[string]*_bintree_t{
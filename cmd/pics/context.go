@@ -6,20 +6,25 @@ import (
 	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/rwcarlsen/gallery/piclib"
 )
 
 type context struct {
-	photos    []*Photo
-	CurrPage  string
-	random    []int
-	randIndex int
+	photos     []*Photo
+	CurrPage   string
+	random     []int
+	randIndex  int
+	authorized bool // true if hidden pics are included
 }
 
-func newContext(pics []*Photo) *context {
+func newContext(pics []*Photo, authorized bool) *context {
 	c := &context{
-		photos:   pics,
-		CurrPage: "1",
+		photos:     pics,
+		CurrPage:   "1",
+		authorized: authorized,
 	}
 	return c
 }
@@ -40,6 +45,86 @@ func (c *context) saveNotes(r *http.Request, picIndex string) error {
 	return p.SetNotes(string(data))
 }
 
+func (c *context) rate(w http.ResponseWriter, picIndex, val string) error {
+	i, err := strconv.Atoi(picIndex)
+	if err != nil || i < 0 || i >= len(c.photos) {
+		return fmt.Errorf("invalid rate request for pic index %v", picIndex)
+	}
+
+	p := c.photos[i]
+	switch val {
+	case "pick":
+		err = p.SetFlag(piclib.Picked)
+	case "reject":
+		err = p.SetFlag(piclib.Rejected)
+	case "unflag":
+		err = p.SetFlag(piclib.Unflagged)
+	default:
+		var stars int
+		if stars, err = strconv.Atoi(val); err != nil {
+			return fmt.Errorf("invalid rating '%v'", val)
+		}
+		err = p.SetRating(stars)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, Rating(p.Pic))
+	return nil
+}
+
+func (c *context) rotate(picIndex string, clockwise bool) error {
+	i, err := strconv.Atoi(picIndex)
+	if err != nil || i < 0 || i >= len(c.photos) {
+		return fmt.Errorf("invalid rotate request for pic index %v", picIndex)
+	}
+	return c.photos[i].Rotate(clockwise)
+}
+
+func (c *context) remove(picIndex string) error {
+	i, err := strconv.Atoi(picIndex)
+	if err != nil || i < 0 || i >= len(c.photos) {
+		return fmt.Errorf("invalid delete request for pic index %v", picIndex)
+	}
+
+	p := c.photos[i]
+	if err := lib.Remove(p.Id); err != nil {
+		return err
+	}
+	dropPhoto(p.Id)
+	photos := append([]*Photo{}, c.photos[:i]...)
+	if next := promote(c.allowed(p.Members)); next != nil {
+		photos = append(photos, next)
+	}
+	c.photos = append(photos, c.photos[i+1:]...)
+	c.random = nil
+	return nil
+}
+
+// selection returns the context's pics with the given comma separated ids -
+// or all of them if ids is empty.
+func (c *context) selection(ids string) ([]*piclib.Pic, error) {
+	want := map[int]bool{}
+	for _, s := range strings.Split(ids, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pic id '%v'", s)
+		}
+		want[id] = true
+	}
+
+	var pics []*piclib.Pic
+	for _, p := range c.photos {
+		if len(want) == 0 || want[p.Id] {
+			pics = append(pics, p.Pic)
+		}
+	}
+	return pics, nil
+}
+
 func (c *context) initRand() {
 	if c.random == nil || len(c.random) > len(c.photos) {
 		c.random = rand.Perm(len(c.photos))
@@ -53,7 +138,10 @@ func (c *context) serveSlide(w http.ResponseWriter) error {
 	if c.randIndex++; c.randIndex == len(c.photos) {
 		c.randIndex = 0
 	}
-	return writeImg(w, p.Id, false)
+	if p.rendered() {
+		return p.Render(w, 0, 0)
+	}
+	return writeImg(w, p, false)
 }
 
 func (c *context) servePage(w http.ResponseWriter, pg string) error {
@@ -68,6 +156,7 @@ func (c *context) servePage(w http.ResponseWriter, pg string) error {
 	for i, p := range c.photos[start:end] {
 		pp := *p
 		pp.Index = i + start
+		pp.Members = c.allowed(p.Members)
 		list[i] = pp
 	}
 
@@ -82,7 +171,32 @@ func (c *context) serveZoom(w http.ResponseWriter, index string) error {
 	i, _ := strconv.Atoi(index)
 	p := *c.photos[i]
 	p.Index = i
-	return zoomTmpl.Execute(w, p)
+	p.Members = c.allowed(p.Members)
+
+	pics, err := p.Versions()
+	if err != nil {
+		return err
+	}
+	var versions []*Photo // only those served, which can be linked to
+	for _, v := range pics {
+		if photo, ok := lookupPhoto(v.Id); ok && v.Deleted.IsZero() {
+			versions = append(versions, photo)
+		}
+	}
+	data := struct {
+		Photo
+		Versions []*Photo
+		NoEdit   bool
+	}{p, c.allowed(versions), noedit}
+	return zoomTmpl.Execute(w, data)
+}
+
+// allowed returns the photos that the context may show.
+func (c *context) allowed(photos []*Photo) []*Photo {
+	if c.authorized {
+		return photos
+	}
+	return unhidden(photos)
 }
 
 func (c *context) servePageNav(w http.ResponseWriter) error {
@@ -122,6 +236,12 @@ func (c *context) serveTimeNav(w http.ResponseWriter) error {
 	if len(c.photos) == 0 {
 		return nil
 	}
+	return utilTmpl.ExecuteTemplate(w, "timenav", c.timeNav())
+}
+
+// timeNav returns the first page for each year and month spanned by the
+// context's pics.
+func (c *context) timeNav() []*year {
 	years := make([]*year, 0)
 	maxYear := c.photos[0].Taken.Year()
 	minYear := c.findMinYear()
@@ -151,8 +271,7 @@ func (c *context) serveTimeNav(w http.ResponseWriter) error {
 	yr.reverseMonths()
 	yr.StartPage = yr.Months[0].Page
 	years = append(years, yr)
-
-	return utilTmpl.ExecuteTemplate(w, "timenav", years)
+	return years
 }
 
 func (c *context) pageOf(start int, t time.Time) (page, last int) {
@@ -3,7 +3,25 @@ function keydown(e) {
   if (!e) {
     e = window.event;
   }
-  if (e.which == keys.left) {
+  if ($(e.target).is("textarea, input")) {
+    return true
+  }
+  if (e.which >= keys.zero && e.which <= keys.zero + 5) {
+    rate(currPic, (e.which - keys.zero).toString())
+    return false
+  } else if (e.which >= keys.numzero && e.which <= keys.numzero + 5) {
+    rate(currPic, (e.which - keys.numzero).toString())
+    return false
+  } else if (e.which == keys.p) {
+    rate(currPic, "pick")
+    return false
+  } else if (e.which == keys.x) {
+    rate(currPic, "reject")
+    return false
+  } else if (e.which == keys.u) {
+    rate(currPic, "unflag")
+    return false
+  } else if (e.which == keys.left) {
     currPic -= 1
     if (currPic < 0) {
       currPic = 0
@@ -51,6 +69,35 @@ function saveNotes(index) {
   $.post("/dynamic/save-notes/" + ind, data)
 }
 
+function rate(index, val) {
+  $.post("/dynamic/rate/" + index.toString() + "/" + val, function(data) {
+    $("#pic-rating").text(data)
+  })
+}
+
+function rotate(index, dir) {
+  $.post("/dynamic/rotate/" + index.toString() + "/" + dir, function() {
+    img = $("#zoom-img")
+    img.attr("src", img.attr("src").split("?")[0] + "?" + new Date().getTime())
+  })
+}
+
+function deletePic(index) {
+  if (!confirm("Move this picture to the trash?")) {
+    return
+  }
+  $.post("/dynamic/delete/" + index.toString(), function() {
+    numPhotos -= 1
+    if (index >= numPhotos) {
+      window.location.href = "/"
+    } else {
+      window.location.reload()
+    }
+  }).fail(function(xhr) {
+    alert("delete failed: " + xhr.responseText)
+  })
+}
+
 var picsPerPage = 0
 var numPhotos = 0
 var currPic = getCurrPic()
@@ -58,6 +105,11 @@ var keys = new Object()
 keys.left = 37
 keys.right = 39
 keys.enter = 13
+keys.zero = 48
+keys.numzero = 96
+keys.p = 80
+keys.u = 85
+keys.x = 88
 
 getStats()
 
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/rwcarlsen/gallery/piclib"
+)
+
+func edit(cmd string, args []string) {
+	desc := "check out a writable copy of a picture, edit it and import the result as a new version of it"
+	fs := newFlagSet(cmd, "PIC-ID", desc)
+	editor := fs.String("editor", "", "command to edit the copy with (default $PICS_EDITOR, then $EDITOR)")
+	nolaunch := fs.Bool("nolaunch", false, "don't launch an editor - wait for the copy to be edited elsewhere")
+	dir := fs.String("dir", "", "directory to check the copy out into (default a temporary directory)")
+	file := fs.String("import", "", "import this already edited file as a version instead of checking out a copy")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(fs.Arg(0))
+	check(err)
+	p, err := lib.Open(id)
+	check(err)
+
+	if *file != "" {
+		addVersion(p, *file)
+		return
+	}
+
+	tmp := *dir == ""
+	if tmp {
+		*dir, err = ioutil.TempDir("", "pics-edit-")
+		check(err)
+	}
+	path, err := p.Checkout(*dir)
+	check(err)
+
+	if *editor == "" {
+		*editor = os.Getenv("PICS_EDITOR")
+	}
+	if *editor == "" {
+		*editor = os.Getenv("EDITOR")
+	}
+	if *nolaunch || *editor == "" {
+		fmt.Printf("edit %v and then press enter to import it (the file is kept on errors)\n", path)
+		bufio.NewReader(os.Stdin).ReadString('\n')
+	} else {
+		argv := append(strings.Fields(*editor), path)
+		c := exec.Command(argv[0], argv[1:]...)
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := c.Run(); err != nil {
+			log.Fatalf("[ERROR] %v: %v (the copy is kept at %v)", *editor, err, path)
+		}
+	}
+
+	if !addVersion(p, path) {
+		log.Fatalf("the copy is kept at %v", path)
+	}
+	os.Remove(path)
+	if tmp {
+		os.Remove(*dir)
+	}
+}
+
+// addVersion imports the file at path as a version of p, returning false if
+// it wasn't imported.
+func addVersion(p *piclib.Pic, path string) bool {
+	v, err := lib.AddVersion(p, path)
+	if err == piclib.ErrUnchanged {
+		fmt.Printf("[SKIP] %v: %v\n", path, err)
+		return true
+	} else if piclib.IsDup(err) {
+		fmt.Printf("[SKIP] %v\n", err)
+		return false
+	} else if err != nil {
+		log.Printf("[ERROR] %v: %v\n", path, err)
+		return false
+	}
+	fmt.Printf("[VERSION] %v (%v) of %v (%v)\n", v.Id, v.Name, p.Id, p.Name)
+	return true
+}
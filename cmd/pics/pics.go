@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	gosync "sync" // sync is the name of a subcommand
 	"time"
 
 	"github.com/kierdavis/dateparser"
@@ -22,19 +23,39 @@ import (
 )
 
 var libpath = flag.String("lib", piclib.DefaultPath(), "path to picture library")
+var inclHidden = flag.Bool("include-hidden", false, "include hidden/private pictures in listings")
 
 type CmdFunc func(cmd string, args []string)
 
 var cmds = map[string]CmdFunc{
-	"add":      add,
-	"validate": validate,
-	"list":     list,
-	"fix":      fix,
-	"link":     link,
-	"copy":     cpy,
-	"serve":    serve,
-	"view":     view,
-	"note":     note,
+	"add":         add,
+	"import-card": importCard,
+	"validate":    validate,
+	"list":        list,
+	"fix":         fix,
+	"link":        link,
+	"copy":        cpy,
+	"serve":       serve,
+	"view":        view,
+	"note":        note,
+	"geotag":      geotag,
+	"timeshift":   timeshift,
+	"date":        date,
+	"tag":         tag,
+	"rate":        rate,
+	"adjust":      adjust,
+	"rotate":      rotate,
+	"rm":          rm,
+	"trash":       trash,
+	"hide":        hide,
+	"export":      export,
+	"publish":     publish,
+	"sync":        sync,
+	"backup":      backup,
+	"watch":       watch,
+	"stack":       stack,
+	"unstack":     unstack,
+	"edit":        edit,
 }
 
 func newFlagSet(cmd, args, desc string) *flag.FlagSet {
@@ -69,6 +90,7 @@ func main() {
 	var err error
 	lib, err = piclib.Open(*libpath)
 	check(err)
+	lib.IncludeHidden = *inclHidden
 
 	cmd, ok := cmds[flag.Arg(0)]
 	if !ok {
@@ -81,8 +103,17 @@ func main() {
 func add(cmd string, args []string) {
 	desc := "copies given files into the library (file names can be piped from stdin)"
 	fs := newFlagSet(cmd, "[FILE...]", desc)
+	datesrc := fs.String("datesrc", "", "comma separated date sources to try in order (default exif,filename,xmp,video,mtime)")
+	workers := fs.Int("j", 1, "number of files to add concurrently")
+	setLimits := decodeFlags(fs)
+	autoStack := stackFlags(fs, "")
 	fs.Parse(args)
 
+	setLimits()
+	if *datesrc != "" {
+		lib.DateSources = dateSources(*datesrc)
+	}
+
 	files := fs.Args()
 	if len(files) == 0 {
 		data, err := ioutil.ReadAll(os.Stdin)
@@ -90,29 +121,175 @@ func add(cmd string, args []string) {
 		files = strings.Fields(string(data))
 	}
 
+	paths := make(chan string)
+	var wg gosync.WaitGroup
+	for i := 0; i < *workers || i == 0; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				p, err := lib.AddFile(path)
+				if piclib.IsDup(err) {
+					fmt.Printf("[SKIP] %v\n", err)
+				} else if err != nil {
+					log.Printf("[ERROR] %v: %v\n", path, err)
+				} else {
+					fmt.Printf("[ADD] %v\n", p.Name)
+					autoStack(p)
+				}
+			}
+		}()
+	}
 	for _, path := range files {
-		path := strings.TrimSpace(path)
-		if path == "" {
+		if path := strings.TrimSpace(path); path != "" {
+			paths <- path
+		}
+	}
+	close(paths)
+	wg.Wait()
+}
+
+// decodeFlags registers options limiting the memory used to decode images on
+// fs and returns a function applying them to the library once fs is parsed.
+func decodeFlags(fs *flag.FlagSet) func() {
+	mem := fs.Int64("mem", piclib.DefaultDecodeMemory>>20, "memory in MB that concurrent image decodes may use")
+	maxpix := fs.Int("max-pixels", piclib.DefaultMaxPixels, "refuse to decode images with more pixels than this")
+	return func() {
+		lib.DecodeMemory = *mem << 20
+		lib.MaxPixels = *maxpix
+	}
+}
+
+func importCard(cmd string, args []string) {
+	desc := "import the pictures from a camera memory card's DCIM directory"
+	fs := newFlagSet(cmd, "MOUNTPOINT", desc)
+	datesrc := fs.String("datesrc", "", "comma separated date sources to try in order (default exif,filename,xmp,video,mtime)")
+	erase := fs.Bool("erase", false, "delete files from the card once their checksums are confirmed in the library")
+	autoStack := stackFlags(fs, "")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *datesrc != "" {
+		lib.DateSources = dateSources(*datesrc)
+	}
+
+	groups, err := piclib.ScanCard(fs.Arg(0))
+	check(err)
+	if !piclib.DCFDirs(groups) && len(groups) > 0 {
+		log.Printf("no DCF directories found - importing everything in DCIM")
+	}
+	pc, err := lib.NewPrecheck()
+	check(err)
+
+	var nfiles, pairs, added, skipped, warned, failed, erased, sidecars int
+	lib.Warn = func(msg string) {
+		log.Printf("[WARN] %v\n", msg)
+		warned++
+	}
+	for _, g := range groups {
+		if g.Pair() {
+			pairs++
+		}
+		sidecars += len(g.Sidecars)
+		for _, path := range g.Files {
+			nfiles++
+			if p, err := pc.Match(path); err != nil {
+				log.Printf("[ERROR] %v: %v\n", path, err)
+				failed++
+				continue
+			} else if p != nil {
+				fmt.Printf("[SKIP] %v already exists in the library as %v\n", path, p.Id)
+				skipped++
+				continue
+			}
+
+			p, err := lib.AddFile(path)
+			if piclib.IsDup(err) {
+				fmt.Printf("[SKIP] %v\n", err)
+				skipped++
+			} else if err != nil {
+				log.Printf("[ERROR] %v: %v\n", path, err)
+				failed++
+			} else {
+				fmt.Printf("[ADD] %v\n", p.Name)
+				autoStack(p)
+				added++
+			}
+		}
+
+		if !*erase {
 			continue
 		}
+		for _, path := range g.Files {
+			if _, err := lib.Imported(path); err != nil {
+				log.Printf("[KEEP] %v: %v\n", path, err)
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				log.Printf("[ERROR] %v\n", err)
+				continue
+			}
+			fmt.Printf("[ERASE] %v\n", path)
+			erased++
+		}
+	}
 
-		p, err := lib.AddFile(path)
-		if piclib.IsDup(err) {
-			fmt.Printf("[SKIP] %v\n", err)
-		} else if err != nil {
-			log.Printf("[ERROR] %v: %v\n", path, err)
-		} else {
-			fmt.Printf("[ADD] %v\n", p.Name)
+	fmt.Printf("%v files (%v raw+jpeg pairs, %v sidecars): %v added, %v already in library, %v warnings, %v failed",
+		nfiles, pairs, sidecars, added, skipped, warned, failed)
+	if *erase {
+		fmt.Printf(", %v erased", erased)
+	}
+	fmt.Println()
+}
+
+func dateSources(names string) []piclib.DateSource {
+	var srcs []piclib.DateSource
+	for _, name := range strings.Split(names, ",") {
+		src, ok := piclib.LookupDateSource(strings.TrimSpace(name))
+		if !ok {
+			log.Fatalf("unknown date source '%v'", name)
 		}
+		srcs = append(srcs, src)
 	}
+	return srcs
 }
 
 func validate(cmd string, args []string) {
-	desc := "verifies checksums of given files (piped from list subcmd is supported)"
+	desc := "verifies checksums of given files (piped from list subcmd is supported) and records the results"
 	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
 	all := fs.Bool("all", false, "true validate every file in the library")
 	v := fs.Bool("v", false, "verbose outadd")
+	scrub := fs.Bool("scrub", false, "validate the least recently verified files first (resumes where the last scrub stopped)")
+	n := fs.Int("n", 0, "with -scrub, maximum number of files to validate (0 for no limit)")
+	age := fs.Float64("age", 0, "with -scrub or -report, skip/count files verified within this many days")
+	rate := fs.Float64("rate", 0, "with -scrub, limit reading to this many MB per second (0 for no limit)")
+	report := fs.Bool("report", false, "print recorded validation results instead of validating")
 	fs.Parse(args)
+	lib.IncludeHidden = true // maintenance covers private pics too
+
+	maxage := time.Duration(*age * 24 * float64(time.Hour))
+	if *report {
+		st, err := lib.ScrubStatus(maxage)
+		check(err)
+		writeScrubStatus(os.Stdout, st, maxage)
+		return
+	}
+
+	if *scrub {
+		opts := piclib.ScrubOptions{MaxAge: maxage, Limit: *n, Rate: int64(*rate * 1e6)}
+		_, err := lib.Scrub(opts, func(p *piclib.Pic, err error) {
+			if err != nil {
+				log.Printf("[ERROR] %v\n", err)
+			} else if *v {
+				fmt.Printf("[VALID] %v (%v)\n", p.Filepath(), p.Name)
+			}
+		})
+		check(err)
+		return
+	}
 
 	var err error
 	var pics []*piclib.Pic
@@ -124,7 +301,7 @@ func validate(cmd string, args []string) {
 	}
 
 	for _, p := range pics {
-		err := p.Validate()
+		err := p.Check()
 		if err != nil {
 			log.Printf("[ERROR] %v\n", err)
 		} else if *v {
@@ -144,7 +321,7 @@ func Untracked() []string {
 	pics, err := lib.List(0, 0)
 	check(err)
 
-	picmap := map[string]bool{piclib.Libname: true}
+	picmap := map[string]bool{}
 	for _, p := range pics {
 		picmap[filepath.Base(p.Filepath())] = true
 	}
@@ -152,7 +329,7 @@ func Untracked() []string {
 	untracked := []string{}
 	for _, name := range names {
 		_, ok := picmap[name]
-		if !ok {
+		if !ok && !piclib.IsReserved(name) {
 			untracked = append(untracked, name)
 		}
 	}
@@ -161,10 +338,131 @@ func Untracked() []string {
 
 func fix(cmd string, args []string) {
 	desc := "perform library maintenance"
-	fs := newFlagSet(cmd, "", desc)
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
 	untracked := fs.Bool("untracked", false, "print untracked files in the library directory")
 	fnames := fs.Bool("fnames", false, "fix miss-named files in the library directory")
+	dates := fs.Bool("dates", false, "infer taken dates for pics that have none")
+	repair := fs.Bool("repair", false, "restore files that failed validation from parity files or replicas")
+	replicas := fs.String("replicas", os.Getenv("PICLIB_REPLICAS"), "comma separated backup dirs, libraries or http mirrors to repair from")
+	parity := fs.Bool("parity", false, "create or refresh parity files for the given pics (piped from list subcmd is supported)")
+	all := fs.Bool("all", false, "with -parity, cover every pic in the library")
+	redundancy := fs.Int("redundancy", piclib.DefaultRedundancy, "with -parity, parity size as a percentage of each file's size")
+	force := fs.Bool("force", false, "with -parity, rewrite parity files that are already up to date; with -thumbs, rebuild every thumbnail")
+	vacuum := fs.Bool("vacuum", false, "move thumbnails of older libraries into the cache, remove unused cached thumbnails and compact the library database")
+	thumbs := fs.Bool("thumbs", false, "rebuild thumbnails missing from the library's cache directory")
+	setLimits := decodeFlags(fs)
 	fs.Parse(args)
+	setLimits()
+	lib.IncludeHidden = true // maintenance covers private pics too
+
+	if *vacuum {
+		dbpath := filepath.Join(*libpath, piclib.Libname)
+		before, err := os.Stat(dbpath)
+		check(err)
+		n, err := lib.Vacuum()
+		check(err)
+		after, err := os.Stat(dbpath)
+		check(err)
+		fmt.Printf("removed %v unused cache files, database shrank from %.1f MB to %.1f MB\n", n, float64(before.Size())/1e6, float64(after.Size())/1e6)
+		return
+	}
+
+	if *thumbs {
+		n, err := lib.RebuildCache(*force, func(p *piclib.Pic, err error) {
+			if piclib.IsUnsupported(err) {
+				fmt.Printf("[SKIP] %v (%v): %v\n", p.Id, p.Name, err)
+			} else if err != nil {
+				log.Printf("[ERROR] %v (%v): %v\n", p.Id, p.Name, err)
+			} else {
+				fmt.Printf("[THUMB] %v (%v)\n", p.Id, p.Name)
+			}
+		})
+		check(err)
+		fmt.Printf("rebuilt %v thumbnails\n", n)
+		return
+	}
+
+	if *parity {
+		var err error
+		var pics []*piclib.Pic
+		if *all {
+			pics, err = lib.List(0, 0)
+			check(err)
+		} else {
+			pics = idsOrStdin(fs.Args())
+		}
+		for _, p := range pics {
+			if !*force && p.HasParity(*redundancy) {
+				continue
+			}
+			if err := p.WriteParity(*redundancy); err != nil {
+				log.Printf("[ERROR] %v (%v): %v\n", p.Id, p.Name, err)
+				continue
+			}
+			fmt.Printf("[PARITY] %v (%v)\n", p.Id, p.Name)
+		}
+		return
+	}
+
+	if *repair {
+		var reps []piclib.Replica
+		for _, loc := range strings.Split(*replicas, ",") {
+			if loc = strings.TrimSpace(loc); loc == "" {
+				continue
+			}
+			rep, err := piclib.ParseReplica(loc)
+			check(err)
+			reps = append(reps, rep)
+		}
+
+		st, err := lib.ScrubStatus(0)
+		check(err)
+		for _, p := range st.Failed {
+			n, err := p.RepairParity()
+			if err == nil {
+				fmt.Printf("[REPAIR] %v (%v) from parity (%v damaged blocks)\n", p.Id, p.Name, n)
+				continue
+			} else if err != piclib.ErrNoParity {
+				log.Printf("[ERROR] %v (%v): parity: %v\n", p.Id, p.Name, err)
+			}
+			if len(reps) == 0 {
+				log.Printf("[ERROR] %v (%v): no replicas given (use -replicas or PICLIB_REPLICAS)\n", p.Id, p.Name)
+				continue
+			}
+
+			rep, err := p.Repair(reps)
+			if err != nil {
+				log.Printf("[ERROR] %v (%v): %v\n", p.Id, p.Name, err)
+				continue
+			}
+			fmt.Printf("[REPAIR] %v (%v) from %v\n", p.Id, p.Name, rep)
+		}
+		return
+	}
+
+	if *dates {
+		// the library copy's mtime is the time it was added - not useful
+		lib.DateSources = dateSources("exif,filename,xmp,video")
+		pics, err := lib.List(0, 0)
+		check(err)
+		for _, p := range pics {
+			if !p.Taken.IsZero() {
+				continue
+			}
+			f, err := os.Open(p.Filepath())
+			check(err)
+			t, src, err := lib.InferTaken(p.Name, f)
+			f.Close()
+			if err != nil {
+				fmt.Printf("[SKIP] %v (%v): %v\n", p.Id, p.Name, err)
+				continue
+			}
+			check(p.SetTaken(t))
+			check(p.SetMeta(piclib.TakenSourceField, src))
+			fmt.Printf("[DATE] %v (%v): %v from %v\n", p.Id, p.Name, t.Format(time.RFC3339), src)
+		}
+		return
+	}
 
 	if *untracked {
 		names := Untracked()
@@ -266,6 +564,8 @@ func cpy(cmd string, args []string) {
 	desc := "copy identified pics out of the library (pipe from list subcmd is supported)"
 	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
 	dst := fs.String("dst", "./copy-pics", "destination directory for the copies")
+	xmp := fs.Bool("xmp", false, "write an XMP sidecar with notes, tags, rating and location next to each copy")
+	orig := fs.Bool("orig", false, "copy original files instead of rendering edited or re-oriented pics")
 	fs.Parse(args)
 
 	pics := idsOrStdin(fs.Args())
@@ -274,25 +574,161 @@ func cpy(cmd string, args []string) {
 	check(err)
 
 	for _, p := range pics {
+		mod, err := p.Modified()
+		check(err)
+		render := mod && !*orig
+
 		ext := filepath.Ext(p.Name)
 		pname := p.Name[:len(p.Name)-len(ext)]
+		if render {
+			ext = ".jpg"
+		}
 		pname = fmt.Sprintf("%v-%v%v", pname, p.Id, ext)
 		copypath := filepath.Join(*dst, pname)
-		_, err := os.Stat(copypath)
+		_, err = os.Stat(copypath)
 		if err == nil {
 			log.Fatalf("destination file '%v' exists", copypath)
 		}
 
 		f, err := os.Create(copypath)
 		check(err)
-		r, err := p.Open()
+		if render {
+			err = p.Render(f, 0, 0)
+			check(err)
+		} else {
+			r, err := p.Open()
+			check(err)
+			_, err = io.Copy(f, r)
+			check(err)
+			r.Close()
+		}
+		f.Close()
+
+		if *xmp {
+			x, err := p.XMP()
+			check(err)
+			f, err := os.Create(copypath + ".xmp")
+			check(err)
+			_, err = x.WriteTo(f)
+			check(err)
+			f.Close()
+		}
+	}
+}
+
+func export(cmd string, args []string) {
+	desc := "stream identified pics into a zip or tar archive (pipe from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	format := fs.String("format", "zip", "archive format ('zip' or 'tar')")
+	out := fs.String("o", "", "archive file to write (default stdout)")
+	name := fs.String("name", piclib.DefaultExportName, "entry name template ({name}, {ext}, {id}, {date}, {year}, {month}, {sum})")
+	manifest := fs.String("manifest", "", "include a manifest of notes, tags and dates ('json' or 'csv')")
+	orig := fs.Bool("orig", false, "archive original files instead of rendering edited or re-oriented pics")
+	fs.Parse(args)
+
+	opts := piclib.ExportOptions{Format: *format, Name: *name, Manifest: *manifest, Orig: *orig}
+	check(opts.Validate())
+	pics := idsOrStdin(fs.Args())
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
 		check(err)
-		_, err = io.Copy(f, r)
+		defer f.Close()
+		w = f
+	}
+	check(piclib.Export(w, pics, opts))
+}
+
+func publish(cmd string, args []string) {
+	desc := "generate a static html gallery of identified pics (pipe from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	dst := fs.String("dst", "./site", "directory to write the site into (regenerated incrementally)")
+	title := fs.String("title", "Photos", "gallery title")
+	size := fs.Int("size", 1600, "max width and height of full size photos")
+	all := fs.Bool("all", false, "publish every (non-hidden) file in the library")
+	fs.Parse(args)
+
+	var pics []*piclib.Pic
+	var err error
+	if *all {
+		pics, err = lib.List(0, 0)
 		check(err)
+	} else {
+		pics = idsOrStdin(fs.Args())
+	}
 
-		f.Close()
-		r.Close()
+	s, err := newSite(*dst, *title, *size, pics)
+	check(err)
+	check(s.publish())
+}
+
+func sync(cmd string, args []string) {
+	desc := "merge pictures and meta data from another library into this one (safe to re-run)"
+	fs := newFlagSet(cmd, "", desc)
+	from := fs.String("from", "", "path to the library to merge from")
+	fs.Parse(args)
+
+	if *from == "" {
+		log.Fatal("-from is required")
+	} else if _, err := os.Stat(filepath.Join(*from, piclib.Libname)); err != nil {
+		log.Fatalf("%v is not a picture library: %v", *from, err)
+	}
+	src, err := piclib.Open(*from)
+	check(err)
+
+	rep, err := lib.Sync(src)
+	for _, p := range rep.Added {
+		fmt.Printf("[ADD] %v (%v)\n", p.Id, p.Name)
 	}
+	for _, p := range rep.Updated {
+		fmt.Printf("[UPDATE] %v (%v)\n", p.Id, p.Name)
+	}
+	for _, c := range rep.Conflicts {
+		fmt.Printf("[CONFLICT] %v (%v) notes: local %q, remote %q\n", c.Pic.Id, c.Pic.Name, c.Local, c.Remote)
+	}
+	check(err)
+}
+
+func backup(cmd string, args []string) {
+	desc := "incrementally back up the library to DEST (or verify an existing backup)"
+	fs := newFlagSet(cmd, "DEST", desc)
+	keep := fs.Int("keep", 5, "number of database snapshots to retain (0 for all)")
+	verify := fs.Bool("verify", false, "verify the backup against its signed manifests instead of backing up")
+	keyfile := fs.String("key", "", "manifest signing key file (default is the library's "+piclib.BackupKeyName+")")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	dest := fs.Arg(0)
+
+	if !*verify {
+		m, copied, err := lib.Backup(dest, *keep)
+		check(err)
+		fmt.Printf("[BACKUP] %v (%v files, %v new)\n", m.Snapshot, len(m.Files), copied)
+		return
+	}
+
+	var key []byte
+	var err error
+	if *keyfile != "" {
+		key, err = ioutil.ReadFile(*keyfile)
+	} else {
+		key, err = lib.BackupKey()
+	}
+	check(err)
+
+	problems, err := piclib.VerifyBackup(dest, key)
+	check(err)
+	for _, err := range problems {
+		fmt.Printf("[BAD] %v\n", err)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("[VALID] %v\n", dest)
 }
 
 func list(cmd string, args []string) {
@@ -300,6 +736,11 @@ func list(cmd string, args []string) {
 	fs := newFlagSet(cmd, "", desc)
 	after := fs.String("from", "", "only show photos after date")
 	before := fs.String("to", "", "only show photos before date")
+	datesrc := fs.String("datesrc", "", "only show photos whose date came from this source (e.g. mtime)")
+	minrating := fs.Int("rating", 0, "only show photos rated at least this many stars")
+	flagged := fs.String("flag", "", "only show photos flagged as 'pick' or 'reject'")
+	label := fs.String("label", "", "only show photos with this color label")
+	collapse := fs.Bool("collapse", false, "only show one pic from each stack")
 	fs.Parse(args)
 
 	var err error
@@ -322,11 +763,53 @@ func list(cmd string, args []string) {
 		}
 	}
 
-	pics, err := lib.ListTime(at, bt)
+	var pics []*piclib.Pic
+	switch {
+	case *minrating > 0:
+		pics, err = lib.ListRated(*minrating)
+	case *flagged == "pick":
+		pics, err = lib.ListFlagged(piclib.Picked)
+	case *flagged == "reject":
+		pics, err = lib.ListFlagged(piclib.Rejected)
+	case *flagged != "":
+		log.Fatalf("invalid flag '%v'", *flagged)
+	case *label != "":
+		pics, err = lib.ListLabel(*label)
+	default:
+		pics, err = lib.ListTime(at, bt)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	var filtered []*piclib.Pic
+	for _, p := range pics {
+		switch {
+		case p.Taken.After(bt) || p.TakenEnd.Before(at):
+		case p.Rating < *minrating:
+		case *flagged != "" && p.Flag.String() != *flagged:
+		case *label != "" && p.Label != *label:
+		default:
+			filtered = append(filtered, p)
+		}
+	}
+	pics = filtered
+
+	if *datesrc != "" {
+		var filtered []*piclib.Pic
+		for _, p := range pics {
+			src, err := p.GetMeta(piclib.TakenSourceField)
+			check(err)
+			if src == *datesrc {
+				filtered = append(filtered, p)
+			}
+		}
+		pics = filtered
+	}
+	if *collapse {
+		pics = piclib.CollapseStacks(pics)
+	}
+
 	err = WriteLines(os.Stdout, pics...)
 	check(err)
 }
@@ -363,18 +846,361 @@ func note(cmd string, args []string) {
 	}
 }
 
+func geotag(cmd string, args []string) {
+	desc := "set pictures' locations from GPX track logs (piped from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	gpx := fs.String("gpx", "", "comma separated list of GPX files to match against")
+	offset := fs.Duration("offset", 0, "how far the camera clock was ahead of the GPS clock")
+	maxgap := fs.Duration("maxgap", 5*time.Minute, "max time between a pic and the nearest track point")
+	dry := fs.Bool("n", false, "dry run - report matches without modifying the library")
+	fs.Parse(args)
+
+	if *gpx == "" {
+		log.Fatal("no GPX files specified")
+	}
+
+	var track piclib.Track
+	for _, fname := range strings.Split(*gpx, ",") {
+		f, err := os.Open(fname)
+		check(err)
+		t, err := piclib.ParseGPX(f)
+		f.Close()
+		check(err)
+		track = append(track, t...)
+	}
+	sort.Sort(track)
+
+	pics := idsOrStdin(fs.Args())
+	for _, p := range pics {
+		pt, ok := track.Locate(p.Taken.Add(-*offset), *maxgap)
+		if !ok {
+			fmt.Printf("[SKIP] %v (%v): no track point within %v\n", p.Id, p.Name, *maxgap)
+			continue
+		}
+
+		fmt.Printf("[TAG] %v (%v): %.6f,%.6f\n", p.Id, p.Name, pt.Lat, pt.Lon)
+		if !*dry {
+			err := p.SetLocation(pt.Lat, pt.Lon)
+			check(err)
+		}
+	}
+}
+
+func timeshift(cmd string, args []string) {
+	desc := "correct the time pictures were taken (piped from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	by := fs.Duration("by", 0, "shift taken times by this duration (e.g. -5h30m)")
+	set := fs.String("set", "", "set taken times to this date")
+	ref := fs.Int("ref", 0, "id of a reference pic from the camera being corrected")
+	match := fs.Int("match", 0, "id of a pic taken at the same moment as -ref by a correct camera")
+	reftime := fs.String("reftime", "", "the correct taken date for the -ref pic")
+	fs.Parse(args)
+
+	shift := *by
+	if *ref != 0 {
+		if *by != 0 || *set != "" {
+			log.Fatal("-ref can't be combined with -by or -set")
+		}
+		rp, err := lib.Open(*ref)
+		check(err)
+
+		var t time.Time
+		if *match != 0 {
+			mp, err := lib.Open(*match)
+			check(err)
+			t = mp.Taken
+		} else if *reftime != "" {
+			t = parseDate(*reftime)
+		} else {
+			log.Fatal("-ref requires either -match or -reftime")
+		}
+		shift = t.Sub(rp.Taken)
+	} else if *set != "" && *by != 0 {
+		log.Fatal("-by and -set can't be combined")
+	} else if *set == "" && *by == 0 {
+		log.Fatal("one of -by, -set or -ref must be given")
+	}
+
+	pics := idsOrStdin(fs.Args())
+	for _, p := range pics {
+		old := p.Taken
+		var err error
+		if *set != "" {
+			err = p.SetTaken(parseDate(*set))
+		} else {
+			err = p.ShiftTaken(shift)
+		}
+		check(err)
+		fmt.Printf("[SHIFT] %v (%v): %v -> %v\n", p.Id, p.Name, old.Format(time.RFC3339), p.Taken.Format(time.RFC3339))
+	}
+}
+
+func date(cmd string, args []string) {
+	desc := "print or set possibly approximate taken dates (piped from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	set := fs.String("set", "", "date to set (e.g. '1987-07-04', 'Jul 1987', 'summer 1987', '1987', '1960s', 'around 1960', '1985..1990')")
+	fs.Parse(args)
+
+	pics := idsOrStdin(fs.Args())
+	if *set == "" {
+		for _, p := range pics {
+			fmt.Printf("%v\t%v\t%v\n", p.Id, p.Precision, p.When())
+		}
+		return
+	}
+
+	d, err := piclib.ParseDate(*set)
+	check(err)
+	for _, p := range pics {
+		check(p.SetDate(d))
+		fmt.Printf("[DATE] %v (%v): %v\n", p.Id, p.Name, d)
+	}
+}
+
+func parseDate(s string) time.Time {
+	reftime := time.Date(time.Now().Year(), 1, 1, 0, 0, 0, 0, time.Local)
+	pars := &dateparser.Parser{Default: reftime}
+	t, err := pars.Parse(s)
+	check(err)
+	return t
+}
+
+func adjust(cmd string, args []string) {
+	desc := "print or modify pictures' non-destructive edit stacks (piped from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	rotate := fs.Float64("rotate", 0, "rotate clockwise by a multiple of 90 degrees")
+	straighten := fs.Float64("straighten", 0, "rotate clockwise by a small angle in degrees (auto-cropped)")
+	crop := fs.String("crop", "", "crop to 'x,y,w,h' given as fractions of the current image size")
+	flip := fs.String("flip", "", "flip 'h'orizontally or 'v'ertically")
+	exposure := fs.Float64("exposure", 0, "adjust exposure by this many stops")
+	contrast := fs.Float64("contrast", 0, "adjust contrast by this percent (-100 to 100)")
+	undo := fs.Bool("undo", false, "remove the most recent edit")
+	revert := fs.Bool("revert", false, "remove all edits, restoring the original")
+	fs.Parse(args)
+
+	var edits []piclib.Edit
+	if *rotate != 0 {
+		edits = append(edits, piclib.Edit{Op: piclib.OpRotate, Amount: *rotate})
+	}
+	if *straighten != 0 {
+		edits = append(edits, piclib.Edit{Op: piclib.OpStraighten, Amount: *straighten})
+	}
+	if *crop != "" {
+		e := piclib.Edit{Op: piclib.OpCrop}
+		_, err := fmt.Sscanf(*crop, "%g,%g,%g,%g", &e.X, &e.Y, &e.W, &e.H)
+		check(err)
+		edits = append(edits, e)
+	}
+	if *flip != "" {
+		edits = append(edits, piclib.Edit{Op: piclib.OpFlip, Dir: *flip})
+	}
+	if *exposure != 0 {
+		edits = append(edits, piclib.Edit{Op: piclib.OpExposure, Amount: *exposure})
+	}
+	if *contrast != 0 {
+		edits = append(edits, piclib.Edit{Op: piclib.OpContrast, Amount: *contrast})
+	}
+
+	pics := idsOrStdin(fs.Args())
+	for _, p := range pics {
+		switch {
+		case *revert:
+			check(p.RevertEdits())
+		case *undo:
+			check(p.UndoEdit())
+		case len(edits) > 0:
+			curr, err := p.Edits()
+			check(err)
+			check(p.SetEdits(append(curr, edits...)))
+		}
+
+		curr, err := p.Edits()
+		check(err)
+		var strs []string
+		for _, e := range curr {
+			strs = append(strs, e.String())
+		}
+		fmt.Printf("%v\t%v\n", p.Id, strings.Join(strs, "; "))
+	}
+}
+
+func rotate(cmd string, args []string) {
+	desc := "override pictures' EXIF orientation (piped from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	left := fs.Bool("left", false, "rotate 90 degrees counter-clockwise")
+	right := fs.Bool("right", false, "rotate 90 degrees clockwise")
+	set := fs.Int("set", 0, "set the EXIF orientation (1 through 8)")
+	fs.Parse(args)
+
+	pics := idsOrStdin(fs.Args())
+	for _, p := range pics {
+		switch {
+		case *set != 0:
+			check(p.SetOrient(*set))
+		case *left:
+			check(p.Rotate(false))
+		case *right:
+			check(p.Rotate(true))
+		}
+		fmt.Printf("%v\t%v\n", p.Id, p.Orient)
+	}
+}
+
+func rate(cmd string, args []string) {
+	desc := "set pictures' star ratings, pick/reject flags and color labels (piped from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	stars := fs.Int("stars", -1, "star rating from 0 (unrated) to 5")
+	pick := fs.Bool("pick", false, "flag pictures as picks")
+	reject := fs.Bool("reject", false, "flag pictures as rejects")
+	unflag := fs.Bool("unflag", false, "clear pick/reject flags")
+	label := fs.String("label", "", "color label ("+strings.Join(piclib.Labels, ", ")+" or none)")
+	fs.Parse(args)
+
+	mark := piclib.Unflagged
+	nflags := 0
+	for f, set := range map[piclib.Flag]bool{piclib.Picked: *pick, piclib.Rejected: *reject, piclib.Unflagged: *unflag} {
+		if set {
+			mark = f
+			nflags++
+		}
+	}
+	if nflags > 1 {
+		log.Fatal("only one of -pick, -reject and -unflag may be given")
+	}
+
+	pics := idsOrStdin(fs.Args())
+	for _, p := range pics {
+		if *stars >= 0 {
+			check(p.SetRating(*stars))
+		}
+		if nflags > 0 {
+			check(p.SetFlag(mark))
+		}
+		if *label == "none" {
+			check(p.SetLabel(""))
+		} else if *label != "" {
+			check(p.SetLabel(*label))
+		}
+	}
+	WriteLines(os.Stdout, pics...)
+}
+
+func tag(cmd string, args []string) {
+	desc := "print or modify pictures' tags (piped from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	addtags := fs.String("add", "", "comma separated tags to add")
+	rmtags := fs.String("rm", "", "comma separated tags to remove")
+	fs.Parse(args)
+
+	pics := idsOrStdin(fs.Args())
+	for _, p := range pics {
+		tags, err := p.Tags()
+		check(err)
+		if *addtags == "" && *rmtags == "" {
+			fmt.Printf("%v\t%v\n", p.Id, strings.Join(tags, ","))
+			continue
+		}
+
+		rm := map[string]bool{}
+		for _, t := range strings.Split(*rmtags, ",") {
+			rm[strings.TrimSpace(t)] = true
+		}
+		var keep []string
+		for _, t := range append(tags, strings.Split(*addtags, ",")...) {
+			if !rm[strings.TrimSpace(t)] {
+				keep = append(keep, t)
+			}
+		}
+		check(p.SetTags(keep))
+	}
+}
+
+func rm(cmd string, args []string) {
+	desc := "move pictures to the library's trash (piped from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	fs.Parse(args)
+
+	pics := idsOrStdin(fs.Args())
+	for _, p := range pics {
+		check(lib.Remove(p.Id))
+		fmt.Printf("[TRASH] %v (%v)\n", p.Id, p.Name)
+	}
+}
+
+func trash(cmd string, args []string) {
+	desc := "list, restore or purge pictures in the library's trash"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	restore := fs.Bool("restore", false, "restore the identified pics from the trash")
+	purge := fs.Int("purge", -1, "permanently delete pics that have been in the trash at least this many days")
+	fs.Parse(args)
+
+	if *restore {
+		for _, p := range idsOrStdin(fs.Args()) {
+			check(lib.Restore(p.Id))
+			fmt.Printf("[RESTORE] %v (%v)\n", p.Id, p.Name)
+		}
+	} else if *purge >= 0 {
+		purged, err := lib.Purge(time.Duration(*purge) * 24 * time.Hour)
+		for _, p := range purged {
+			fmt.Printf("[PURGE] %v (%v)\n", p.Id, p.Name)
+		}
+		check(err)
+	} else {
+		pics, err := lib.ListTrash()
+		check(err)
+		check(WriteLines(os.Stdout, pics...))
+	}
+}
+
+func hide(cmd string, args []string) {
+	desc := "mark pictures as hidden/private so they are excluded from default listings (piped from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	unhide := fs.Bool("unhide", false, "make hidden pictures visible again")
+	fs.Parse(args)
+
+	pics := idsOrStdin(fs.Args())
+	for _, p := range pics {
+		check(p.SetHidden(!*unhide))
+	}
+	WriteLines(os.Stdout, pics...)
+}
+
 func serve(cmd string, args []string) {
 	desc := "serve listed pics in a browser-based picture gallery (or piped from stdin)"
 	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
 	fs.StringVar(&addr, "addr", "127.0.0.1:7777", "ip and port to serve gallery at")
+	fs.StringVar(&secret, "secret", "", "password required (via /unlock?secret=...) to see hidden pictures")
 	view := fs.Bool("view", false, "opens browser window to gallery page")
 	fs.BoolVar(&noedit, "noedit", false, "don't allow editing of anything in library")
 	fs.BoolVar(&all, "all", false, "true to view every file in the library")
+	scrubdays := fs.Float64("scrub", 0, "validate every file in the background once per this many days (0 to disable)")
+	scrubrate := fs.Float64("scrubrate", 1, "limit background validation to this many MB per second")
+	fs.BoolVar(&expandStacks, "stacks", false, "show every pic in a stack instead of just the stack's top pic")
+	watchdirs := fs.String("watch", "", "comma separated directories to import new files from while serving")
+	newWatcher := watchFlags(fs, "watch-")
+	autoStack := stackFlags(fs, "watch-")
+	setLimits := decodeFlags(fs)
 	fs.Parse(args)
 
+	setLimits()
+
 	l, err := net.Listen("tcp", addr)
 	check(err)
 	go runserve(l, fs.Args())
+	if *scrubdays > 0 {
+		scrubAge = time.Duration(*scrubdays * 24 * float64(time.Hour))
+		go scrubLoop(scrubAge, int64(*scrubrate*1e6))
+	}
+	if *watchdirs != "" {
+		w := newWatcher(strings.Split(*watchdirs, ","))
+		w.added = func(p *piclib.Pic) {
+			autoStack(p)
+			if all { // otherwise only the listed pics are served
+				addPhoto(p)
+			}
+		}
+		go func() { check(w.run()) }()
+	}
 
 	log.Printf("serving on address %v", addr)
 	if *view {
@@ -390,7 +1216,9 @@ func view(cmd string, args []string) {
 	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
 	fs.BoolVar(&noedit, "noedit", false, "don't allow editing of anything in library")
 	fs.StringVar(&addr, "addr", "127.0.0.1:", "ip and port to serve gallery at")
+	fs.StringVar(&secret, "secret", "", "password required (via /unlock?secret=...) to see hidden pictures")
 	fs.BoolVar(&all, "all", false, "true to view every file in the library")
+	fs.BoolVar(&expandStacks, "stacks", false, "show every pic in a stack instead of just the stack's top pic")
 	fs.Parse(args)
 
 	l, err := net.Listen("tcp", addr)
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rwcarlsen/gallery/piclib"
+)
+
+// pubState records the source of each published rendition so unchanged
+// images aren't re-rendered.
+const pubState = ".pics-publish.json"
+
+var videoExts = map[string]bool{".avi": true, ".m4v": true, ".mov": true, ".mp4": true}
+
+// pubStatic maps embedded assets to their paths in a published site.
+var pubStatic = map[string]string{
+	"data/static/bootstrap/css/bootstrap.min.css": "static/bootstrap.min.css",
+	"data/static/my.css":                          "static/my.css",
+	"data/static/favicon.ico":                     "static/favicon.ico",
+}
+
+// site is a static html gallery generated from a set of photos.
+type site struct {
+	dir    string
+	title  string
+	size   int // max width and height of full size renditions
+	photos []*Photo
+	tmpl   *template.Template
+	state  map[string]string // rendition keys by pic id
+}
+
+type gridPage struct {
+	Title, Root                string
+	Page, Prev, Next, NumPages int
+	Pages                      []int
+	Photos                     []*Photo
+	Years                      []*year
+}
+
+type photoPage struct {
+	*Photo
+	Title, Root string
+	Page        int
+	Prev, Next  int // ids of neighboring pics (0 if none)
+	Notes       string
+	Tags        []string
+}
+
+func pageName(pg int) string {
+	if pg <= 1 {
+		return "index.html"
+	}
+	return fmt.Sprintf("page-%v.html", pg)
+}
+
+func newSite(dir, title string, size int, pics []*piclib.Pic) (*site, error) {
+	data, err := Asset("data/publish.html")
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("publish").Funcs(template.FuncMap{"page": pageName}).Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &site{dir: dir, title: title, size: size, tmpl: tmpl, state: map[string]string{}}
+	pics = append([]*piclib.Pic{}, pics...)
+	sort.Stable(sort.Reverse(Piclist(pics))) // most recent first like the time nav expects
+	for _, p := range pics {
+		if !videoExts[p.Ext()] {
+			s.photos = append(s.photos, &Photo{Pic: p, Index: len(s.photos)})
+		}
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(dir, pubState)); err == nil {
+		if err := json.Unmarshal(data, &s.state); err != nil {
+			return nil, fmt.Errorf("corrupt publish state: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// publish writes the site, only touching files whose content has changed.
+func (s *site) publish() error {
+	for _, sub := range []string{"static", "photos", "img", "thumbs"} {
+		if err := os.MkdirAll(filepath.Join(s.dir, sub), 0755); err != nil {
+			return err
+		}
+	}
+
+	for asset, dst := range pubStatic {
+		data, err := Asset(asset)
+		if err != nil {
+			return err
+		}
+		if err := s.write(dst, data); err != nil {
+			return err
+		}
+	}
+
+	npages := (len(s.photos) + picsPerPage - 1) / picsPerPage
+	if npages == 0 {
+		npages = 1
+	}
+	var years []*year
+	if len(s.photos) > 0 {
+		years = newContext(s.photos, true).timeNav()
+	}
+	for _, y := range years {
+		y.StartPage = min(y.StartPage, npages)
+		for _, m := range y.Months {
+			m.Page = min(m.Page, npages)
+		}
+	}
+
+	pages := make([]int, npages)
+	for i := range pages {
+		pages[i] = i + 1
+	}
+	for pg := 1; pg <= npages; pg++ {
+		start := (pg - 1) * picsPerPage
+		end := min(start+picsPerPage, len(s.photos))
+		data := gridPage{
+			Title:    s.title,
+			Page:     pg,
+			Prev:     pg - 1,
+			Next:     pg + 1,
+			NumPages: npages,
+			Pages:    pages,
+			Photos:   s.photos[start:end],
+			Years:    years,
+		}
+		if err := s.render(pageName(pg), "pubgrid", data); err != nil {
+			return err
+		}
+	}
+
+	for i, p := range s.photos {
+		if err := s.publishPhoto(i); err != nil {
+			return fmt.Errorf("pic %v: %v", p.Id, err)
+		}
+	}
+
+	if err := s.clean(npages); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(s.dir, pubState), data, 0644)
+}
+
+func (s *site) publishPhoto(i int) error {
+	p := s.photos[i]
+	data := photoPage{Photo: p, Title: s.title, Root: "../", Page: i/picsPerPage + 1}
+	if i > 0 {
+		data.Prev = s.photos[i-1].Id
+	}
+	if i+1 < len(s.photos) {
+		data.Next = s.photos[i+1].Id
+	}
+
+	var err error
+	if data.Notes, err = p.GetNotes(); err != nil {
+		return err
+	}
+	if data.Tags, err = p.Tags(); err != nil {
+		return err
+	}
+	if err := s.render(fmt.Sprintf("photos/%v.html", p.Id), "pubphoto", data); err != nil {
+		return err
+	}
+
+	// only re-render images if the pic's content or edits changed
+	edits, err := p.GetMeta(piclib.EditsField)
+	if err != nil {
+		return err
+	}
+	id := strconv.Itoa(p.Id)
+	key := fmt.Sprintf("%x %v %v %v", p.Sum, p.Orient, s.size, edits)
+	img := fmt.Sprintf("img/%v.jpg", p.Id)
+	thumb := fmt.Sprintf("thumbs/%v.jpg", p.Id)
+	if s.state[id] == key && s.exists(img) && s.exists(thumb) {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := p.Render(&buf, s.size, s.size); err != nil {
+		return err
+	}
+	if err := s.write(img, buf.Bytes()); err != nil {
+		return err
+	}
+	tdata, err := p.Thumb()
+	if err != nil {
+		return err
+	}
+	if err := s.write(thumb, tdata); err != nil {
+		return err
+	}
+	s.state[id] = key
+	return nil
+}
+
+func (s *site) render(name, tmpl string, data interface{}) error {
+	var buf bytes.Buffer
+	if err := s.tmpl.ExecuteTemplate(&buf, tmpl, data); err != nil {
+		return err
+	}
+	return s.write(name, buf.Bytes())
+}
+
+func (s *site) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(s.dir, name))
+	return err == nil
+}
+
+// write writes data to the named file in the site unless it already has
+// exactly that content.
+func (s *site) write(name string, data []byte) error {
+	path := filepath.Join(s.dir, name)
+	if old, err := ioutil.ReadFile(path); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("[WRITE] %v\n", name)
+	return nil
+}
+
+// clean removes pages and images left over from pics that are no longer
+// published.
+func (s *site) clean(npages int) error {
+	keep := map[string]bool{}
+	for _, p := range s.photos {
+		keep[strconv.Itoa(p.Id)] = true
+	}
+	for id := range s.state {
+		if !keep[id] {
+			delete(s.state, id)
+		}
+	}
+
+	var stale []string
+	for _, sub := range []string{"photos", "img", "thumbs"} {
+		names, err := filepath.Glob(filepath.Join(s.dir, sub, "*"))
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			base := filepath.Base(name)
+			if !keep[strings.TrimSuffix(base, filepath.Ext(base))] {
+				stale = append(stale, filepath.Join(sub, base))
+			}
+		}
+	}
+
+	pages, err := filepath.Glob(filepath.Join(s.dir, "page-*.html"))
+	if err != nil {
+		return err
+	}
+	for _, name := range pages {
+		base := filepath.Base(name)
+		pg, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(base, "page-"), ".html"))
+		if err == nil && pg > npages {
+			stale = append(stale, base)
+		}
+	}
+
+	for _, name := range stale {
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+			return err
+		}
+		fmt.Printf("[REMOVE] %v\n", name)
+	}
+	return nil
+}
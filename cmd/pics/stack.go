@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/rwcarlsen/gallery/piclib"
+)
+
+// stackFlags registers automatic stacking options on fs with the given name
+// prefix and returns a function stacking each newly added pic accordingly.
+func stackFlags(fs *flag.FlagSet, prefix string) func(p *piclib.Pic) {
+	nostack := fs.Bool(prefix+"nostack", false, "don't stack added pics with RAW+JPEG partners, live photo videos or other burst frames")
+	window := fs.Duration(prefix+"stack-window", 0, "also stack pics taken within this long of each other as bursts (0 to disable)")
+	return func(p *piclib.Pic) {
+		if *nostack {
+			return
+		}
+		top, err := lib.AutoStack(p, *window)
+		if err != nil {
+			log.Printf("[ERROR] stacking %v: %v\n", p.Name, err)
+		} else if top != nil && top.Id == p.Id {
+			fmt.Printf("[STACK] %v (%v) as the top of its stack\n", p.Id, p.Name)
+		} else if top != nil {
+			fmt.Printf("[STACK] %v (%v) under %v (%v)\n", p.Id, p.Name, top.Id, top.Name)
+		}
+	}
+}
+
+func stack(cmd string, args []string) {
+	desc := "group pictures that are versions of the same shot into a stack (piped from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	topid := fs.Int("top", 0, "id of the pic to show for the stack (default keeps the current top or picks one)")
+	auto := fs.Bool("auto", false, "stack every pic in the library with its RAW+JPEG partners, live photo videos and burst frames")
+	window := fs.Duration("window", 0, "with -auto, also stack pics taken within this long of each other")
+	fs.Parse(args)
+	lib.IncludeHidden = true
+
+	if *auto {
+		pics, err := lib.List(0, 0)
+		check(err)
+		done := map[int]bool{}
+		for _, p := range pics {
+			if done[p.Id] {
+				continue
+			}
+			top, err := lib.AutoStack(p, *window)
+			check(err)
+			if top == nil {
+				continue
+			}
+			members, err := top.StackMembers()
+			check(err)
+			for _, m := range members {
+				done[m.Id] = true
+			}
+			fmt.Printf("[STACK] %v (%v): %v pics\n", top.Id, top.Name, len(members))
+		}
+		return
+	}
+
+	pics := idsOrStdin(fs.Args())
+	var top *piclib.Pic
+	if *topid != 0 {
+		var err error
+		top, err = lib.Open(*topid)
+		check(err)
+	}
+	check(lib.Stack(pics, top))
+	members, err := pics[0].StackMembers()
+	check(err)
+	WriteLines(os.Stdout, members...)
+}
+
+func unstack(cmd string, args []string) {
+	desc := "remove pictures from their stacks (piped from list subcmd is supported)"
+	fs := newFlagSet(cmd, "[PIC-ID...]", desc)
+	all := fs.Bool("all", false, "dissolve the whole stack of each given pic")
+	fs.Parse(args)
+
+	pics := idsOrStdin(fs.Args())
+	for _, p := range pics {
+		if *all {
+			check(p.Dissolve())
+		} else {
+			check(p.Unstack())
+		}
+	}
+	WriteLines(os.Stdout, pics...)
+}
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/rwcarlsen/gallery/piclib"
 )
@@ -45,11 +46,47 @@ func WriteLines(w io.Writer, pics ...*piclib.Pic) error {
 		}
 
 		tm := p.Taken
-		fmt.Fprintf(tw, "%v\t%v\t%v\t\"%v\"\t%v\n", p.Id, tm.Unix(), tm.Format("2006/1/2"), nm, notes)
+		date := tm.Format("2006/1/2")
+		if p.Precision > piclib.Day {
+			date = p.When().String()
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t\"%v\"\t%v\n", p.Id, tm.Unix(), date, Rating(p), nm, notes)
 	}
 	return nil
 }
 
+// Rating summarizes a pic's stars, flag, label and visibility e.g. "*** pick red".
+func Rating(p *piclib.Pic) string {
+	s := strings.Repeat("*", p.Rating)
+	if s == "" {
+		s = "-"
+	}
+	if p.Flag != piclib.Unflagged {
+		s += " " + p.Flag.String()
+	}
+	if p.Label != "" {
+		s += " " + p.Label
+	}
+	if p.Hidden {
+		s += " hidden"
+	}
+	return s
+}
+
+func writeScrubStatus(w io.Writer, st *piclib.ScrubStatus, age time.Duration) {
+	fmt.Fprintf(w, "%v files, %v never validated", st.Total, st.Never)
+	if age > 0 {
+		fmt.Fprintf(w, ", %v not validated in %v days", st.Due, age.Hours()/24)
+	}
+	fmt.Fprintln(w)
+	if !st.Oldest.IsZero() {
+		fmt.Fprintf(w, "oldest validation: %v\n", st.Oldest.Format("2006/1/2 15:04"))
+	}
+	for _, p := range st.Failed {
+		fmt.Fprintf(w, "[ERROR] %v (%v) at %v: %v\n", p.Id, p.Name, p.Checked.Format("2006/1/2 15:04"), p.CheckErr)
+	}
+}
+
 func ParseLines(r io.Reader) (picids []int, err error) {
 	buf := bufio.NewReader(r)
 
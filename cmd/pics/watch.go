@@ -0,0 +1,314 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/gallery/piclib"
+)
+
+// notifier watches directories for changes, sending the path of each changed
+// entry (or "" if changes may have been missed) to its events channel.
+type notifier interface {
+	add(dir string) error
+}
+
+// watcher imports files that appear in a set of directories once they have
+// stopped changing.
+type watcher struct {
+	dirs   []string
+	settle time.Duration // how long a file must be unchanged before import
+	poll   time.Duration // rescan interval when inotify is unavailable
+	moveto string        // if set, imported files are moved here
+	remove bool          // delete imported files
+	added  func(p *piclib.Pic)
+
+	pending map[string]*candidate
+	seen    map[string]fileSig // files already imported or skipped
+}
+
+type fileSig struct {
+	size int64
+	mod  time.Time
+}
+
+type candidate struct {
+	root  string // watched directory containing the file
+	sig   fileSig
+	since time.Time // when sig was last seen to change
+}
+
+// watchFlags registers watcher options on fs with the given name prefix and
+// returns a function creating a watcher for dirs from them.
+func watchFlags(fs *flag.FlagSet, prefix string) func(dirs []string) *watcher {
+	settle := fs.Duration(prefix+"settle", 5*time.Second, "import files once they have been unchanged for this long")
+	poll := fs.Duration(prefix+"poll", 10*time.Second, "how often to rescan directories if inotify is unavailable")
+	moveto := fs.String(prefix+"move", "", "move imported files into this directory")
+	remove := fs.Bool(prefix+"delete", false, "delete imported files")
+	return func(dirs []string) *watcher {
+		if *moveto != "" && *remove {
+			log.Fatalf("-%vmove and -%vdelete can't be used together", prefix, prefix)
+		}
+		return &watcher{
+			dirs:    dirs,
+			settle:  *settle,
+			poll:    *poll,
+			moveto:  *moveto,
+			remove:  *remove,
+			pending: map[string]*candidate{},
+			seen:    map[string]fileSig{},
+		}
+	}
+}
+
+func watch(cmd string, args []string) {
+	desc := "import files as they appear in the given directories"
+	fs := newFlagSet(cmd, "DIR...", desc)
+	datesrc := fs.String("datesrc", "", "comma separated date sources to try in order (default exif,filename,xmp,video,mtime)")
+	newWatcher := watchFlags(fs, "")
+	setLimits := decodeFlags(fs)
+	autoStack := stackFlags(fs, "")
+	fs.Parse(args)
+
+	setLimits()
+
+	if fs.NArg() == 0 {
+		log.Fatal("no directories given")
+	}
+	if *datesrc != "" {
+		lib.DateSources = dateSources(*datesrc)
+	}
+	w := newWatcher(fs.Args())
+	w.added = autoStack
+	check(w.run())
+}
+
+// run watches until an error occurs.
+func (w *watcher) run() error {
+	for _, dir := range w.dirs {
+		if info, err := os.Stat(dir); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return fmt.Errorf("%v is not a directory", dir)
+		}
+	}
+
+	events := make(chan string, 100)
+	n, err := newNotifier(events)
+	var rescan <-chan time.Time
+	if err != nil {
+		log.Printf("inotify unavailable (%v) - polling every %v", err, w.poll)
+		rescan = time.Tick(w.poll)
+	}
+	for _, dir := range w.dirs {
+		w.scan(dir, dir, n)
+	}
+
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case path := <-events:
+			if path == "" { // events were dropped
+				for _, dir := range w.dirs {
+					w.scan(dir, dir, n)
+				}
+			} else if root := w.root(path); root != "" {
+				w.notice(root, path, n)
+			}
+		case <-rescan:
+			for _, dir := range w.dirs {
+				w.scan(dir, dir, nil)
+			}
+		case <-tick.C:
+			w.importStable()
+		}
+	}
+}
+
+// ignored returns true for hidden files and files that sync and download
+// tools use while transferring.
+func ignored(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "~") {
+		return true
+	}
+	for _, ext := range []string{".tmp", ".part", ".partial", ".crdownload"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// root returns the watched directory containing path.
+func (w *watcher) root(path string) string {
+	for _, dir := range w.dirs {
+		if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return dir
+		}
+	}
+	return ""
+}
+
+// scan notices every file under dir, adding dir and its subdirectories to n
+// if it isn't nil.
+func (w *watcher) scan(root, dir string, n notifier) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			log.Printf("[ERROR] %v\n", err)
+			return nil
+		}
+		if info.IsDir() {
+			if path != dir && ignored(info.Name()) || w.moveto != "" && sameFile(path, w.moveto) {
+				return filepath.SkipDir
+			}
+			if n != nil {
+				if err := n.add(path); err != nil {
+					log.Printf("[ERROR] watching %v: %v\n", path, err)
+				}
+			}
+			return nil
+		}
+		w.notice(root, path, nil)
+		return nil
+	})
+}
+
+// notice queues path for import once it stops changing.
+func (w *watcher) notice(root, path string, n notifier) {
+	info, err := os.Stat(path)
+	if err != nil || ignored(info.Name()) {
+		return
+	} else if info.IsDir() {
+		w.scan(root, path, n) // catch files created before the watch was added
+		return
+	} else if !info.Mode().IsRegular() {
+		return
+	}
+
+	sig := fileSig{info.Size(), info.ModTime()}
+	if seen, ok := w.seen[path]; ok && seen == sig {
+		return
+	}
+	if c, ok := w.pending[path]; !ok || c.sig != sig {
+		w.pending[path] = &candidate{root: root, sig: sig, since: time.Now()}
+	}
+}
+
+// importStable imports pending files that haven't changed within the settle
+// time.
+func (w *watcher) importStable() {
+	for path, c := range w.pending {
+		info, err := os.Stat(path)
+		if err != nil {
+			delete(w.pending, path)
+			continue
+		}
+		if sig := (fileSig{info.Size(), info.ModTime()}); sig != c.sig {
+			c.sig, c.since = sig, time.Now()
+			continue
+		} else if time.Since(c.since) < w.settle {
+			continue
+		}
+
+		delete(w.pending, path)
+		w.seen[path] = c.sig
+		if w.importFile(path) {
+			delete(w.seen, path)
+			w.dispose(c.root, path)
+		}
+	}
+}
+
+// importFile adds path to the library, returning true once the library's
+// copy has been verified.
+func (w *watcher) importFile(path string) bool {
+	p, err := lib.AddFile(path)
+	if piclib.IsDup(err) {
+		fmt.Printf("[SKIP] %v\n", err)
+		return false
+	} else if err != nil {
+		log.Printf("[ERROR] %v: %v\n", path, err)
+		return false
+	}
+	if err := p.Validate(); err != nil {
+		log.Printf("[ERROR] %v: import failed verification: %v\n", path, err)
+		return false
+	}
+
+	fmt.Printf("[ADD] %v\n", p.Name)
+	if w.added != nil {
+		w.added(p)
+	}
+	return true
+}
+
+// dispose moves or deletes an imported file as configured.
+func (w *watcher) dispose(root, path string) {
+	if w.remove {
+		if err := os.Remove(path); err != nil {
+			log.Printf("[ERROR] %v\n", err)
+			return
+		}
+		fmt.Printf("[DELETE] %v\n", path)
+	} else if w.moveto != "" {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = filepath.Base(path)
+		}
+		dst := filepath.Join(w.moveto, rel)
+		if err := moveFile(path, dst); err != nil {
+			log.Printf("[ERROR] %v\n", err)
+			return
+		}
+		fmt.Printf("[MOVE] %v -> %v\n", path, dst)
+	}
+}
+
+// moveFile renames src to dst, falling back to copying across file systems.
+// An existing dst is never overwritten.
+func moveFile(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("can't move %v: %v already exists", src, dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+func sameFile(a, b string) bool {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	return err == nil && os.SameFile(ia, ib)
+}
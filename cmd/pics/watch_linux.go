@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	gosync "sync" // sync is the name of a subcommand
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_MODIFY
+
+type inotify struct {
+	fd     int
+	events chan<- string
+	mu     gosync.Mutex
+	dirs   map[int32]string // watched directories by watch descriptor
+}
+
+func newNotifier(events chan<- string) (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	n := &inotify{fd: fd, events: events, dirs: map[int32]string{}}
+	go n.read()
+	return n, nil
+}
+
+func (n *inotify) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.dirs[int32(wd)] = dir
+	n.mu.Unlock()
+	return nil
+}
+
+func (n *inotify) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		nr, err := syscall.Read(n.fd, buf)
+		if err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			log.Printf("[ERROR] inotify: %v\n", err)
+			return
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= nr; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			start := off + syscall.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[start:start+int(ev.Len)], "\x00"))
+			off = start + int(ev.Len)
+
+			n.mu.Lock()
+			dir, ok := n.dirs[ev.Wd]
+			if ev.Mask&syscall.IN_IGNORED != 0 { // the directory was removed
+				delete(n.dirs, ev.Wd)
+			}
+			n.mu.Unlock()
+
+			if ev.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				n.events <- ""
+			} else if ok && name != "" {
+				n.events <- filepath.Join(dir, name)
+			}
+		}
+	}
+}
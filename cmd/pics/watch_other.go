@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newNotifier(events chan<- string) (notifier, error) {
+	return nil, fmt.Errorf("not supported on %v", runtime.GOOS)
+}
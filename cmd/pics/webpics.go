@@ -1,13 +1,17 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
 	"path"
+	"sort"
 	"strconv"
+	gosync "sync" // sync is the name of a subcommand
 	"text/template"
 	"time"
 
@@ -37,28 +41,49 @@ var (
 )
 
 var (
+	photosMu  gosync.RWMutex // guards allPhotos and picMap
 	allPhotos = []*Photo{}
 	picMap    = map[int]*Photo{}
 	contexts  = make(map[string]*context)
-	store     = sessions.NewCookieStore([]byte("my-secret"))
+	store     = sessions.NewCookieStore(randomKey())
 	slidepage []byte // slideshow.html
 )
 
 type Photo struct {
 	*piclib.Pic
-	Index int
+	Index   int
+	Members []*Photo // the rest of the pic's stack when stacks are collapsed
 }
 
 func (p Photo) Date() string {
+	if p.Precision > piclib.Day {
+		return p.When().String()
+	}
 	return p.Taken.Format("Jan 2, 2006")
 }
 
+func (p Photo) Rating() string { return Rating(p.Pic) }
+
+// StackSize is the number of pics the photo represents.
+func (p Photo) StackSize() int { return len(p.Members) + 1 }
+
 func (p Photo) Style() string {
-	t := fmt.Sprintf("transform:rotate(%vdeg)", rots[p.Orient])
+	rot := rots[p.Orient]
+	if p.rendered() { // orientation already applied
+		rot = 0
+	}
+	t := fmt.Sprintf("transform:rotate(%vdeg)", rot)
 	//Cross-browser
 	return fmt.Sprintf("-moz-%s; -webkit-%s; -ms-%s; -o-%s; %s;", t, t, t, t, t)
 }
 
+// rendered reports whether the photo is served through Render (which applies
+// its orientation) rather than as its original file.
+func (p Photo) rendered() bool {
+	mod, err := p.Modified()
+	return (err == nil && mod) || piclib.IsRaw(p.Name)
+}
+
 func init() {
 	zt, err := Asset("data/zoompic.html")
 	check(err)
@@ -76,8 +101,43 @@ var (
 	noedit bool
 	addr   string
 	all    bool
+	secret string // required to view hidden pics
+	// expandStacks shows every pic in a stack rather than just its top
+	expandStacks bool
+
+	scrubAge  time.Duration // how often every file is validated in the background
+	scrubMu   gosync.Mutex  // guards lastScrub
+	lastScrub time.Time     // when background validation last completed a pass
 )
 
+// scrubLoop validates library files in the background so each is checked
+// at least once every age.
+func scrubLoop(age time.Duration, rate int64) {
+	opts := piclib.ScrubOptions{MaxAge: age, Rate: rate}
+	for {
+		_, err := lib.Scrub(opts, func(p *piclib.Pic, err error) {
+			if err != nil {
+				log.Printf("[ERROR] %v\n", err)
+			}
+		})
+		if err != nil {
+			log.Printf("[ERROR] background validation: %v", err)
+		} else {
+			scrubMu.Lock()
+			lastScrub = time.Now()
+			scrubMu.Unlock()
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+func randomKey() []byte {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	check(err)
+	return key
+}
+
 func runserve(l net.Listener, args []string) {
 	var err error
 	slidepage, err = Asset("data/slideshow.html")
@@ -96,10 +156,16 @@ func runserve(l net.Listener, args []string) {
 	r.HandleFunc("/dynamic/set-page/{page:[0-9]+}", SetPageHandler)
 	r.HandleFunc("/dynamic/stat/{stat}", StatHandler)
 	r.HandleFunc("/dynamic/save-notes/{picIndex:[0-9]+}", NotesHandler)
+	r.HandleFunc("/dynamic/rate/{picIndex:[0-9]+}/{rating}", RateHandler)
+	r.HandleFunc("/dynamic/rotate/{picIndex:[0-9]+}/{dir:left|right}", RotateHandler)
+	r.HandleFunc("/dynamic/delete/{picIndex:[0-9]+}", DeleteHandler)
+	r.HandleFunc("/dynamic/export.{format:zip|tar}", ExportHandler)
 	r.HandleFunc("/dynamic/slideshow", SlideshowHandler)
 	r.HandleFunc("/dynamic/next-slide", NextSlideHandler)
 	r.HandleFunc("/dynamic/slide-style", SlideStyleHandler)
 	r.HandleFunc("/dynamic/clickpic/{id}", clickpicHandler)
+	r.HandleFunc("/unlock", UnlockHandler)
+	r.HandleFunc("/status", StatusHandler)
 
 	http.Handle("/", r)
 
@@ -113,21 +179,202 @@ var skipext = []string{"", ".avi", ".m4v", ".go"}
 func loadPics(args []string) {
 	var pics []*piclib.Pic
 	var err error
+	nhidden := 0
 	if all {
+		lib.IncludeHidden = lib.IncludeHidden || secret != "" // unlocked sessions may see them
 		pics, err = lib.List(0, 0)
 		check(err)
 	} else {
 		pics = idsOrStdin(args)
 	}
 
+	photosMu.Lock()
+	var photos []*Photo
 	for _, p := range pics {
 		if p.Ext() == ".avi" || p.Ext() == ".m4v" {
 			continue
 		}
+		if p.Hidden && secret == "" {
+			nhidden++
+			continue
+		}
 		photo := &Photo{Pic: p}
-		allPhotos = append(allPhotos, photo)
+		photos = append(photos, photo)
 		picMap[p.Id] = photo
 	}
+	if expandStacks {
+		allPhotos = append(allPhotos, photos...)
+	} else {
+		allPhotos = append(allPhotos, collapse(photos)...)
+	}
+	photosMu.Unlock()
+	if nhidden > 0 {
+		log.Printf("%v hidden pics are not served (use -secret to allow unlocking them)", nhidden)
+	}
+}
+
+// collapse returns photos with each stack represented by a single photo
+// holding the rest of the stack as its members.
+func collapse(photos []*Photo) []*Photo {
+	pics := make([]*piclib.Pic, len(photos))
+	byId := map[int]*Photo{}
+	for i, p := range photos {
+		pics[i] = p.Pic
+		byId[p.Id] = p
+	}
+
+	var shown []*Photo
+	reps := map[int]*Photo{} // by stack
+	for _, p := range piclib.CollapseStacks(pics) {
+		photo := byId[p.Id]
+		shown = append(shown, photo)
+		if p.Stack != 0 {
+			reps[p.Stack] = photo
+		}
+	}
+	for _, p := range photos {
+		if rep := reps[p.Stack]; rep != nil && rep != p {
+			rep.Members = append(rep.Members, p)
+		}
+	}
+	return shown
+}
+
+// photosFor returns the photos that may be shown to a session.
+func photosFor(authorized bool) []*Photo {
+	photosMu.RLock()
+	defer photosMu.RUnlock()
+	if authorized {
+		return allPhotos
+	}
+	var photos []*Photo
+	for _, p := range allPhotos {
+		if !p.Hidden {
+			photos = append(photos, p)
+		} else if next := promote(unhidden(p.Members)); next != nil {
+			photos = append(photos, next) // a hidden top's visible members
+		}
+	}
+	return photos
+}
+
+// unhidden returns the photos that aren't hidden.
+func unhidden(photos []*Photo) []*Photo {
+	var shown []*Photo
+	for _, p := range photos {
+		if !p.Hidden {
+			shown = append(shown, p)
+		}
+	}
+	return shown
+}
+
+// authorized reports whether the request's session has unlocked hidden pics.
+func authorized(r *http.Request) bool {
+	s, _ := store.Get(r, "dyn-content")
+	return secret != "" && s.Values["authorized"] == true
+}
+
+// dropPhoto removes a deleted photo from the set served to new contexts.  If
+// it represented a stack, the next member of the stack replaces it.  Photos
+// are shared with contexts, so replacements are built rather than modifying
+// them.
+func dropPhoto(id int) {
+	photosMu.Lock()
+	defer photosMu.Unlock()
+	delete(picMap, id)
+	for i, rep := range allPhotos {
+		var repl *Photo
+		if rep.Id == id {
+			repl = promote(rep.Members)
+		} else if j := indexOf(rep.Members, id); j >= 0 {
+			members := append([]*Photo{}, rep.Members[:j]...)
+			repl = &Photo{Pic: rep.Pic, Members: append(members, rep.Members[j+1:]...)}
+		} else {
+			continue
+		}
+		photos := append([]*Photo{}, allPhotos[:i]...)
+		if repl != nil {
+			photos = append(photos, repl)
+		}
+		allPhotos = append(photos, allPhotos[i+1:]...)
+		return
+	}
+}
+
+// promote returns a photo representing a stack by its first member, or nil if
+// there are no members.
+func promote(members []*Photo) *Photo {
+	if len(members) == 0 {
+		return nil
+	}
+	return &Photo{Pic: members[0].Pic, Members: members[1:]}
+}
+
+func indexOf(photos []*Photo, id int) int {
+	for i, p := range photos {
+		if p.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// addPhoto adds a newly imported pic to the set served to new contexts,
+// keeping allPhotos most recently taken first.
+func addPhoto(p *piclib.Pic) {
+	if p.Ext() == ".avi" || p.Ext() == ".m4v" {
+		return
+	}
+	photosMu.Lock()
+	defer photosMu.Unlock()
+	if _, ok := picMap[p.Id]; ok {
+		return
+	}
+	photo := &Photo{Pic: p}
+	picMap[p.Id] = photo
+	if p.Stack != 0 && !expandStacks && addToStack(photo) {
+		return
+	}
+	i := sort.Search(len(allPhotos), func(i int) bool { return !allPhotos[i].Taken.After(p.Taken) })
+	photos := append([]*Photo{}, allPhotos[:i]...)
+	photos = append(photos, photo)
+	allPhotos = append(photos, allPhotos[i:]...)
+}
+
+// addToStack adds a newly stacked photo to the photo representing its stack,
+// replacing that photo if the new one is the stack's top.  It returns false
+// if no photo represents the stack.
+func addToStack(photo *Photo) bool {
+	for i, rep := range allPhotos {
+		if rep.Id != photo.Stack && (rep.Stack == 0 || rep.Stack != photo.Stack) {
+			continue
+		}
+		pic := rep.Pic
+		if pic.Stack != photo.Stack { // rep may have been stacked along with photo
+			cp := *pic
+			cp.Stack = photo.Stack
+			pic = &cp
+		}
+		members := append([]*Photo{}, rep.Members...)
+		repl := &Photo{Pic: pic, Members: append(members, photo)}
+		if photo.Id == photo.Stack {
+			repl = &Photo{Pic: photo.Pic, Members: append(members, &Photo{Pic: pic})}
+		}
+		photos := append([]*Photo{}, allPhotos...)
+		photos[i] = repl
+		allPhotos = photos
+		return true
+	}
+	return false
+}
+
+// lookupPhoto returns the served photo with the given pic id.
+func lookupPhoto(id int) (*Photo, bool) {
+	photosMu.RLock()
+	defer photosMu.RUnlock()
+	p, ok := picMap[id]
+	return p, ok
 }
 
 ///////////////////////////////////////////////////////////
@@ -140,6 +387,48 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func UnlockHandler(w http.ResponseWriter, r *http.Request) {
+	// cookies from before a restart fail to decode - a fresh session is used
+	s, _ := store.Get(r, "dyn-content")
+
+	given := r.FormValue("secret")
+	if secret == "" || subtle.ConstantTimeCompare([]byte(given), []byte(secret)) != 1 {
+		http.Error(w, "invalid secret", http.StatusForbidden)
+		return
+	}
+	s.Values["authorized"] = true
+	s.Save(r, w)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func StatusHandler(w http.ResponseWriter, r *http.Request) {
+	st, err := lib.ScrubStatus(scrubAge)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !authorized(r) {
+		var failed []*piclib.Pic
+		for _, p := range st.Failed {
+			if !p.Hidden {
+				failed = append(failed, p)
+			}
+		}
+		st.Failed = failed
+	}
+	scrubMu.Lock()
+	last := lastScrub
+	scrubMu.Unlock()
+	data := struct {
+		*piclib.ScrubStatus
+		Age  float64
+		Last time.Time
+	}{st, scrubAge.Hours() / 24, last}
+	if err := utilTmpl.ExecuteTemplate(w, "status", data); err != nil {
+		log.Print(err)
+	}
+}
+
 func clickpicHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	fmt.Println(vars["id"])
@@ -169,12 +458,19 @@ func PhotoHandler(w http.ResponseWriter, r *http.Request) {
 		log.Print(err)
 		return
 	}
+	p, ok := lookupPhoto(id)
+	if !ok || p.Hidden && !authorized(r) {
+		http.NotFound(w, r)
+		return
+	}
 
 	switch vars["type"] {
 	case "orig":
-		err = writeImg(w, id, false)
+		err = writeImg(w, p, false)
 	case "thumb":
-		err = writeImg(w, id, true)
+		err = writeImg(w, p, true)
+	case "render":
+		err = p.Render(w, 0, 0)
 	default:
 		log.Printf("invalid pic type %v", vars["type"])
 		return
@@ -185,12 +481,7 @@ func PhotoHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func writeImg(w io.Writer, id int, thumb bool) error {
-	p, ok := picMap[id]
-	if !ok {
-		return fmt.Errorf("%v is not a valid pic id", id)
-	}
-
+func writeImg(w io.Writer, p *Photo, thumb bool) error {
 	if thumb {
 		data, err := p.Thumb()
 		if err != nil {
@@ -235,6 +526,69 @@ func NotesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func RateHandler(w http.ResponseWriter, r *http.Request) {
+	if noedit {
+		return
+	}
+	c, vars := getContext(w, r)
+	if err := c.rate(w, vars["picIndex"], vars["rating"]); err != nil {
+		log.Print(err)
+	}
+}
+
+func RotateHandler(w http.ResponseWriter, r *http.Request) {
+	if noedit {
+		return
+	}
+	c, vars := getContext(w, r)
+	if err := c.rotate(vars["picIndex"], vars["dir"] == "right"); err != nil {
+		log.Print(err)
+	}
+}
+
+func DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if noedit {
+		http.Error(w, "editing is disabled", http.StatusForbidden)
+		return
+	}
+	c, vars := getContext(w, r)
+	if err := c.remove(vars["picIndex"]); err != nil {
+		log.Print(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ExportHandler streams the session's pics (or the subset given by a comma
+// separated ids query parameter) as an archive.
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	c, vars := getContext(w, r)
+	pics, err := c.selection(r.FormValue("ids"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts := piclib.ExportOptions{
+		Format:   vars["format"],
+		Name:     r.FormValue("name"),
+		Manifest: r.FormValue("manifest"),
+		Orig:     r.FormValue("orig") != "",
+	}
+	if err := opts.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctype := "application/zip"
+	if opts.Format == "tar" {
+		ctype = "application/x-tar"
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"pics.%v\"", opts.Format))
+	if err := piclib.Export(w, pics, opts); err != nil {
+		log.Print(err)
+	}
+}
+
 func NextSlideHandler(w http.ResponseWriter, r *http.Request) {
 	c, _ := getContext(w, r)
 	if err := c.serveSlide(w); err != nil {
@@ -285,20 +639,20 @@ func TimeNavHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func getContext(w http.ResponseWriter, r *http.Request) (*context, map[string]string) {
-	s, err := store.Get(r, "dyn-content")
-	if err != nil {
-		panic(err.Error())
-	}
+	// cookies from before a restart fail to decode - a fresh session is used
+	s, _ := store.Get(r, "dyn-content")
 
+	auth := secret != "" && s.Values["authorized"] == true
 	v, ok := s.Values["context-id"]
 	if !ok {
 		v = time.Now().String()
 		s.Values["context-id"] = v
-		contexts[v.(string)] = newContext(allPhotos)
-	} else if _, ok := contexts[v.(string)]; !ok {
+		contexts[v.(string)] = newContext(photosFor(auth), auth)
+	} else if c, ok := contexts[v.(string)]; !ok || c.authorized != auth {
+		delete(contexts, v.(string))
 		v = time.Now().String()
 		s.Values["context-id"] = v
-		contexts[v.(string)] = newContext(allPhotos)
+		contexts[v.(string)] = newContext(photosFor(auth), auth)
 	}
 	s.Save(r, w)
 	c := contexts[v.(string)]
@@ -0,0 +1,353 @@
+package piclib
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rwcarlsen/go-sqlite3"
+)
+
+// BackupKeyName is the file in the library directory holding the key used to
+// sign backup manifests.
+const BackupKeyName = "backup.key"
+
+// Backup directory layout.
+const (
+	backupFiles     = "files"
+	backupSnapshots = "snapshots"
+	backupStamp     = "20060102-150405.000"
+)
+
+// BackupManifest lists the content of one backup snapshot.
+type BackupManifest struct {
+	Created     time.Time
+	Snapshot    string            // database snapshot path relative to the backup
+	SnapshotSum string            // hex sha256 of the snapshot
+	Files       map[string]string // blob name (relative to files/) to hex sha256
+	Signature   string            // hex HMAC-SHA256 of the manifest with Signature empty
+}
+
+func (m *BackupManifest) sign(key []byte) (string, error) {
+	cp := *m
+	cp.Signature = ""
+	data, err := json.Marshal(&cp)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// BackupKey returns the library's manifest signing key, creating it if
+// necessary.  Keep a copy somewhere other than the library and its backups in
+// order to verify backups after losing the library.
+func (l *Lib) BackupKey() ([]byte, error) {
+	path := filepath.Join(l.Path, BackupKeyName)
+	key, err := ioutil.ReadFile(path)
+	if err == nil {
+		return key, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, ioutil.WriteFile(path, key, 0600)
+}
+
+var (
+	backupMu   sync.Mutex
+	hookedConn *sqlite3.SQLiteConn // set by the sqlite3_backup driver's connect hook
+)
+
+func init() {
+	sql.Register("sqlite3_backup", &sqlite3.SQLiteDriver{
+		ConnectHook: func(c *sqlite3.SQLiteConn) error {
+			hookedConn = c
+			return nil
+		},
+	})
+}
+
+// rawConn opens path and returns its underlying sqlite connection.  backupMu
+// must be held.
+func rawConn(path string) (*sql.DB, *sqlite3.SQLiteConn, error) {
+	db, err := sql.Open("sqlite3_backup", path)
+	if err != nil {
+		return nil, nil, err
+	}
+	db.SetMaxOpenConns(1)
+	hookedConn = nil
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return db, hookedConn, nil
+}
+
+// snapshotDB writes a consistent copy of the library database to dst using
+// sqlite's online backup api, so it is safe to run while the library is in
+// use.
+func (l *Lib) snapshotDB(dst string) error {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	srcdb, src, err := rawConn(filepath.Join(l.Path, Libname))
+	if err != nil {
+		return err
+	}
+	defer srcdb.Close()
+	dstdb, dest, err := rawConn(dst)
+	if err != nil {
+		return err
+	}
+	defer dstdb.Close()
+
+	b, err := dest.Backup("main", src, "main")
+	if err != nil {
+		return err
+	}
+	remaining := -1
+	for {
+		done, err := b.Step(256)
+		if err != nil {
+			b.Close()
+			return err
+		} else if done {
+			break
+		}
+		if b.Remaining() == remaining { // the library is locked - wait
+			time.Sleep(50 * time.Millisecond)
+		}
+		remaining = b.Remaining()
+	}
+	return b.Close()
+}
+
+// Backup takes an incremental backup of the library into dest.  A snapshot of
+// the database is always taken, but only files not already in dest are
+// copied.  A signed manifest is written for the snapshot and all but the
+// newest keep snapshots (and files only they reference) are removed.  It
+// returns the manifest and the number of files copied.  Files already in dest
+// are only compared by size - VerifyBackup finds damaged copies, and removing
+// them causes the next backup to copy them again.
+func (l *Lib) Backup(dest string, keep int) (m *BackupManifest, copied int, err error) {
+	key, err := l.BackupKey()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, sub := range []string{backupFiles, backupSnapshots} {
+		if err := os.MkdirAll(filepath.Join(dest, sub), 0755); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	now := time.Now()
+	stamp := now.Format(backupStamp)
+	m = &BackupManifest{
+		Created:  now,
+		Snapshot: filepath.Join(backupSnapshots, "piclib-"+stamp+".sqlite"),
+		Files:    map[string]string{},
+	}
+
+	snap := filepath.Join(dest, m.Snapshot)
+	if err := l.snapshotDB(snap + ".part"); err != nil {
+		os.Remove(snap + ".part")
+		return nil, 0, err
+	}
+	if err := os.Rename(snap+".part", snap); err != nil {
+		return nil, 0, err
+	}
+	sum, err := fileSum(snap)
+	if err != nil {
+		return nil, 0, err
+	}
+	m.SnapshotSum = sum
+
+	// the snapshot (rather than the live db) determines which files belong
+	snaplib := &Lib{Path: l.Path}
+	if snaplib.db, err = sql.Open("sqlite3", snap); err != nil {
+		return nil, 0, err
+	}
+	defer snaplib.db.Close()
+	pics, err := snaplib.queryPics("SELECT " + piccols + " FROM files;")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, p := range pics {
+		name := diskname(p.Name, p.Sum)
+		m.Files[name] = fmt.Sprintf("%x", p.Sum)
+		dst := filepath.Join(dest, backupFiles, name)
+		if sameSize(p.Filepath(), dst) { // already backed up
+			continue
+		}
+		if err := copyVerified(p.Filepath(), dst, p.Sum); err != nil {
+			return nil, copied, fmt.Errorf("pic %v (%v): %v", p.Id, p.Name, err)
+		}
+		copied++
+	}
+
+	if m.Signature, err = m.sign(key); err != nil {
+		return nil, copied, err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, copied, err
+	}
+	mpath := filepath.Join(dest, "manifest-"+stamp+".json")
+	if err := ioutil.WriteFile(mpath, data, 0644); err != nil {
+		return nil, copied, err
+	}
+	return m, copied, pruneBackups(dest, keep)
+}
+
+func sameSize(a, b string) bool {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	return err == nil && ia.Size() == ib.Size()
+}
+
+func fileSum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sum, err := Sha256(f)
+	return fmt.Sprintf("%x", sum), err
+}
+
+// BackupManifests returns the manifests in the backup at dest, oldest first.
+func BackupManifests(dest string) ([]*BackupManifest, error) {
+	names, err := filepath.Glob(filepath.Join(dest, "manifest-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var ms []*BackupManifest
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		m := &BackupManifest{}
+		if err := json.Unmarshal(data, m); err != nil {
+			return nil, fmt.Errorf("invalid backup manifest %v: %v", name, err)
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// pruneBackups removes all but the newest keep snapshots along with files
+// that none of the remaining snapshots reference.
+func pruneBackups(dest string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	names, err := filepath.Glob(filepath.Join(dest, "manifest-*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	if len(names) <= keep {
+		return nil
+	}
+
+	ms, err := BackupManifests(dest)
+	if err != nil {
+		return err
+	}
+	used := map[string]bool{}
+	for _, m := range ms[len(ms)-keep:] {
+		for name := range m.Files {
+			used[name] = true
+		}
+	}
+
+	for i, m := range ms[:len(ms)-keep] {
+		if err := os.Remove(filepath.Join(dest, m.Snapshot)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Remove(names[i]); err != nil {
+			return err
+		}
+		for name := range m.Files {
+			if used[name] {
+				continue
+			}
+			path := filepath.Join(dest, backupFiles, name)
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// VerifyBackup checks every manifest in the backup at dest against key and
+// every snapshot and file against its recorded sum.  Each problem found is
+// returned as a separate error.
+func VerifyBackup(dest string, key []byte) (problems []error, err error) {
+	ms, err := BackupManifests(dest)
+	if err != nil {
+		return nil, err
+	} else if len(ms) == 0 {
+		return nil, fmt.Errorf("no backup manifests found in %v", dest)
+	}
+
+	files := map[string]string{}
+	for _, m := range ms {
+		sig, err := m.sign(key)
+		if err != nil {
+			return nil, err
+		} else if !hmac.Equal([]byte(sig), []byte(m.Signature)) {
+			problems = append(problems, fmt.Errorf("manifest for %v has an invalid signature", m.Snapshot))
+			continue
+		}
+
+		if sum, err := fileSum(filepath.Join(dest, m.Snapshot)); err != nil {
+			problems = append(problems, err)
+		} else if sum != m.SnapshotSum {
+			problems = append(problems, fmt.Errorf("snapshot %v failed checksum validation", m.Snapshot))
+		}
+		for name, sum := range m.Files {
+			files[name] = sum
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sum, err := fileSum(filepath.Join(dest, backupFiles, name))
+		if err != nil {
+			problems = append(problems, err)
+		} else if !strings.EqualFold(sum, files[name]) {
+			problems = append(problems, fmt.Errorf("file %v failed checksum validation", name))
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,194 @@
+package piclib
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CacheDir is the library subdirectory holding thumbnails.  Cache files are
+// named by the checksum of their content and can all be rebuilt from the
+// originals, so backups leave them out.
+const CacheDir = "cache"
+
+func (l *Lib) cachePath(key string) string {
+	return filepath.Join(l.Path, CacheDir, key[:2], key+".jpg")
+}
+
+// putCache stores data in the cache and returns its key.
+func (l *Lib) putCache(data []byte) (key string, err error) {
+	key = fmt.Sprintf("%x", sha256.Sum256(data))
+	path := l.cachePath(key)
+	if info, err := os.Stat(path); err == nil && info.Size() == int64(len(data)) {
+		return key, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.Write(data)
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return key, nil
+}
+
+// cacheThumb stores a thumbnail in the cache, returning the key to record
+// for it (empty if there is no thumbnail).
+func (l *Lib) cacheThumb(thumb []byte) (string, error) {
+	if len(thumb) == 0 {
+		return "", nil
+	}
+	return l.putCache(thumb)
+}
+
+// setThumb replaces the thumbnail of pic id.
+func (l *Lib) setThumb(id int, thumb []byte) error {
+	key, err := l.cacheThumb(thumb)
+	if err != nil {
+		return err
+	}
+	_, err = l.db.Exec("UPDATE files SET thumbkey=?, thumb=NULL WHERE id=?;", key, id)
+	return err
+}
+
+// migrateThumbs moves thumbnails stored in the files table by older versions
+// into the cache.
+func (l *Lib) migrateThumbs() error {
+	for {
+		rows, err := l.db.Query("SELECT id, thumb FROM files WHERE thumb IS NOT NULL LIMIT 100;")
+		if err != nil {
+			return err
+		}
+		thumbs := map[int][]byte{}
+		for rows.Next() {
+			var id int
+			var thumb []byte
+			if err := rows.Scan(&id, &thumb); err != nil {
+				rows.Close()
+				return err
+			}
+			thumbs[id] = thumb
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		} else if len(thumbs) == 0 {
+			return nil
+		}
+
+		for id, thumb := range thumbs {
+			if err := l.setThumb(id, thumb); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// thumbCached returns true if the pic has a thumbnail in the cache.
+func (p *Pic) thumbCached() (bool, error) {
+	key := ""
+	err := p.lib.db.QueryRow("SELECT thumbkey FROM files WHERE id=?;", p.id).Scan(&key)
+	if err != nil {
+		return false, err
+	} else if key == "" {
+		return false, nil
+	}
+	_, err = os.Stat(p.lib.cachePath(key))
+	return err == nil, nil
+}
+
+// RebuildCache regenerates thumbnails missing from the cache (or every
+// thumbnail if all is true) from the original files of all pics, including
+// hidden and deleted ones.  fn is called with each pic rebuilt and the
+// error, if any, from doing so.  It returns the number of pics rebuilt
+// without error.
+func (l *Lib) RebuildCache(all bool, fn func(p *Pic, err error)) (n int, err error) {
+	pics, err := l.queryPics("SELECT " + piccols + " FROM files;")
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range pics {
+		if !all {
+			if ok, err := p.thumbCached(); err != nil {
+				return n, err
+			} else if ok {
+				continue
+			}
+		}
+		err := p.RebuildThumb()
+		if err == nil {
+			n++
+		}
+		if fn != nil {
+			fn(p, err)
+		}
+	}
+	return n, nil
+}
+
+// Vacuum moves any thumbnails left in the database into the cache, removes
+// cache files no pic refers to and compacts the database file.  It returns
+// the number of cache files removed.
+func (l *Lib) Vacuum() (removed int, err error) {
+	if err := l.migrateThumbs(); err != nil {
+		return 0, err
+	}
+
+	rows, err := l.db.Query("SELECT thumbkey FROM files WHERE thumbkey != '';")
+	if err != nil {
+		return 0, err
+	}
+	used := map[string]bool{}
+	for rows.Next() {
+		key := ""
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		used[key] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	err = filepath.Walk(filepath.Join(l.Path, CacheDir), func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil || info.IsDir() {
+			return err
+		}
+		name := info.Name()
+		if used[strings.TrimSuffix(name, filepath.Ext(name))] {
+			return nil
+		} else if strings.HasPrefix(name, ".tmp-") && time.Since(info.ModTime()) < time.Hour {
+			return nil // may still be being written
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, err
+	}
+
+	_, err = l.db.Exec("VACUUM;")
+	return removed, err
+}
@@ -0,0 +1,255 @@
+package piclib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// dcfDir matches the DCF directory names cameras use inside DCIM, e.g.
+// "100CANON".
+var dcfDir = regexp.MustCompile(`^[1-9][0-9]{2}[0-9A-Za-z_]{5}$`)
+
+// sidecarExts are files cameras and editors write next to pics that are not
+// pics themselves.
+var sidecarExts = map[string]bool{".xmp": true, ".thm": true, ".wav": true}
+
+// CardGroup is the set of files in a card directory that share a name -
+// typically one shot saved as RAW and JPEG along with its sidecars.
+type CardGroup struct {
+	Name     string   // path of the files without extension
+	Files    []string // files to import
+	Sidecars []string
+}
+
+// Pair returns true if the group holds both a raw file and a rendered one.
+func (g *CardGroup) Pair() bool {
+	raw, other := false, false
+	for _, f := range g.Files {
+		if IsRaw(f) {
+			raw = true
+		} else {
+			other = true
+		}
+	}
+	return raw && other
+}
+
+// ScanCard returns the file groups found in the DCIM directory of a camera
+// memory card mounted at mount (which may also be the DCIM directory
+// itself).  Besides DCF directories, other non-hidden directories in DCIM
+// (as used by phones) are included.
+func ScanCard(mount string) ([]*CardGroup, error) {
+	dcim := ""
+	if strings.EqualFold(filepath.Base(mount), "DCIM") {
+		dcim = mount
+	} else {
+		infos, err := ioutil.ReadDir(mount)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if info.IsDir() && strings.EqualFold(info.Name(), "DCIM") {
+				dcim = filepath.Join(mount, info.Name())
+			}
+		}
+	}
+	if dcim == "" {
+		return nil, fmt.Errorf("no DCIM directory found in %v", mount)
+	}
+
+	groups := map[string]*CardGroup{}
+	err := filepath.Walk(dcim, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if strings.HasPrefix(name, ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		} else if info.IsDir() || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		// "IMG_0001.JPG.xmp" belongs with "IMG_0001.JPG"
+		ext := strings.ToLower(filepath.Ext(name))
+		stem := strings.TrimSuffix(path, filepath.Ext(path))
+		if sidecarExts[ext] {
+			stem = strings.TrimSuffix(stem, filepath.Ext(stem))
+		}
+		key := strings.ToUpper(stem)
+		g, ok := groups[key]
+		if !ok {
+			g = &CardGroup{Name: stem}
+			groups[key] = g
+		}
+		if sidecarExts[ext] {
+			g.Sidecars = append(g.Sidecars, path)
+		} else {
+			g.Files = append(g.Files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := make([]*CardGroup, len(keys))
+	for i, key := range keys {
+		list[i] = groups[key]
+	}
+	return list, nil
+}
+
+// DCFDirs returns true if a card's DCIM directory holds standard DCF
+// directories.
+func DCFDirs(groups []*CardGroup) bool {
+	for _, g := range groups {
+		if dcfDir.MatchString(filepath.Base(filepath.Dir(g.Name))) {
+			return true
+		}
+	}
+	return false
+}
+
+// quickSize is how much of each end of a file is hashed by a precheck.
+const quickSize = 64 * 1024
+
+// Precheck cheaply detects files that are already in a library by comparing
+// file sizes and then hashes of just the start and end of files before
+// confirming with a full checksum.
+type Precheck struct {
+	bySize map[int64][]*Pic
+	quick  map[*Pic][]byte
+}
+
+// NewPrecheck indexes the sizes of every file in the library - including
+// hidden pics and those in the trash.
+func (l *Lib) NewPrecheck() (*Precheck, error) {
+	pics, err := l.queryPics("SELECT " + piccols + " FROM files;")
+	if err != nil {
+		return nil, err
+	}
+	pc := &Precheck{bySize: map[int64][]*Pic{}, quick: map[*Pic][]byte{}}
+	for _, p := range pics {
+		if info, err := os.Stat(p.Filepath()); err == nil {
+			pc.bySize[info.Size()] = append(pc.bySize[info.Size()], p)
+		}
+	}
+	return pc, nil
+}
+
+// Match returns the library pic that the file at path duplicates (or nil if
+// there is none).  Files are only fully hashed to confirm a probable match.
+func (pc *Precheck) Match(path string) (*Pic, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	cands := pc.bySize[info.Size()]
+	if len(cands) == 0 {
+		return nil, nil
+	}
+
+	sum, err := quickSum(path)
+	if err != nil {
+		return nil, err
+	}
+	var full []byte
+	for _, p := range cands {
+		q, ok := pc.quick[p]
+		if !ok {
+			if q, err = quickSum(p.Filepath()); err != nil {
+				continue
+			}
+			pc.quick[p] = q
+		}
+		if !bytes.Equal(q, sum) {
+			continue
+		}
+		if full == nil {
+			if full, err = fileSha256(path); err != nil {
+				return nil, err
+			}
+		}
+		if bytes.Equal(full, p.Sum) {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+func fileSha256(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Sha256(f)
+}
+
+// quickSum hashes the size and the first and last quickSize bytes of a file.
+func quickSum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, info.Size())
+	if _, err := io.CopyN(h, f, quickSize); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if info.Size() > quickSize {
+		off := info.Size() - quickSize
+		if off < quickSize {
+			off = quickSize
+		}
+		if _, err := f.Seek(off, os.SEEK_SET); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(h, f); err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// Imported confirms that the file at path is in the library: its full
+// checksum must match a pic whose library file passes validation.  It
+// returns that pic.
+func (l *Lib) Imported(path string) (*Pic, error) {
+	sum, err := fileSha256(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := l.scanPic(l.db.QueryRow("SELECT "+piccols+" FROM files WHERE sum=?;", sum))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%v is not in the library", path)
+	} else if err != nil {
+		return nil, err
+	}
+	return p, p.Validate()
+}
@@ -0,0 +1,146 @@
+package piclib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Precision describes how accurately a pic's taken date is known.
+type Precision int
+
+const (
+	Exact Precision = iota
+	Day
+	Month
+	Season
+	Year
+	Decade
+	Range
+)
+
+var precNames = []string{"exact", "day", "month", "season", "year", "decade", "range"}
+
+func (p Precision) String() string {
+	if p < 0 || int(p) >= len(precNames) {
+		return fmt.Sprintf("Precision(%d)", int(p))
+	}
+	return precNames[p]
+}
+
+// Date is a possibly approximate point in time.  Start and End bound the
+// period during which the pic was taken; they are equal for exact dates.
+type Date struct {
+	Start     time.Time
+	End       time.Time
+	Precision Precision
+}
+
+// ExactDate returns a date that is known to the second.
+func ExactDate(t time.Time) Date { return Date{Start: t, End: t, Precision: Exact} }
+
+var seasons = []string{"spring", "summer", "fall", "winter"}
+
+// String formats d so that it can be read back by ParseDate.
+func (d Date) String() string {
+	switch d.Precision {
+	case Day:
+		return d.Start.Format("2006-01-02")
+	case Month:
+		return d.Start.Format("Jan 2006")
+	case Season:
+		season, y := seasons[(int(d.Start.Month())+9)/3%4], d.Start.Year()
+		if d.Start.Month() < time.March { // winter is named for the year of its December
+			y--
+		}
+		return fmt.Sprintf("%v%v %v", strings.ToUpper(season[:1]), season[1:], y)
+	case Year:
+		return fmt.Sprint(d.Start.Year())
+	case Decade:
+		return fmt.Sprintf("%vs", d.Start.Year())
+	case Range:
+		return fmt.Sprintf("%v..%v", d.Start.Format("2006-01-02"), d.End.Format("2006-01-02"))
+	}
+	return d.Start.Format(time.RFC3339)
+}
+
+var (
+	decadePat = regexp.MustCompile(`^([0-9]{3}0)'?s$`)
+	yearPat   = regexp.MustCompile(`^[0-9]{4}$`)
+	seasonPat = regexp.MustCompile(`^(spring|summer|fall|autumn|winter) ([0-9]{4})$`)
+	monthPat  = regexp.MustCompile(`^([0-9]{4})-([0-9]{1,2})$`)
+	approxPat = regexp.MustCompile(`^(around|about|circa|ca\.|c\.|~) *([0-9]{4})$`)
+)
+
+// ParseDate parses exact and approximate dates such as:
+//
+//	2006-01-02T15:04:05  (exact)
+//	1987-07-04           (day)
+//	Jul 1987, 1987-07    (month)
+//	summer 1987          (season - winter runs from December into the next year)
+//	1987                 (year)
+//	1960s                (decade)
+//	around 1960          (range of two years either side)
+//	1985..1990           (range between any two other forms)
+func ParseDate(s string) (Date, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if parts := strings.SplitN(s, "..", 2); len(parts) == 2 {
+		start, err := ParseDate(parts[0])
+		if err != nil {
+			return Date{}, err
+		}
+		end, err := ParseDate(parts[1])
+		if err != nil {
+			return Date{}, err
+		}
+		if end.End.Before(start.Start) {
+			return Date{}, fmt.Errorf("date range '%v' ends before it starts", s)
+		}
+		return Date{Start: start.Start, End: end.End, Precision: Range}, nil
+	}
+
+	if m := approxPat.FindStringSubmatch(s); m != nil {
+		y, _ := strconv.Atoi(m[2])
+		return Date{Start: yearStart(y - 2), End: yearStart(y + 3).Add(-time.Second), Precision: Range}, nil
+	} else if m := decadePat.FindStringSubmatch(s); m != nil {
+		y, _ := strconv.Atoi(m[1])
+		return Date{Start: yearStart(y), End: yearStart(y + 10).Add(-time.Second), Precision: Decade}, nil
+	} else if yearPat.MatchString(s) {
+		y, _ := strconv.Atoi(s)
+		return Date{Start: yearStart(y), End: yearStart(y + 1).Add(-time.Second), Precision: Year}, nil
+	} else if m := seasonPat.FindStringSubmatch(s); m != nil {
+		y, _ := strconv.Atoi(m[2])
+		mo := map[string]time.Month{"spring": 3, "summer": 6, "fall": 9, "autumn": 9, "winter": 12}[m[1]]
+		start := time.Date(y, mo, 1, 0, 0, 0, 0, time.Local)
+		return Date{Start: start, End: start.AddDate(0, 3, 0).Add(-time.Second), Precision: Season}, nil
+	} else if m := monthPat.FindStringSubmatch(s); m != nil {
+		y, _ := strconv.Atoi(m[1])
+		mo, _ := strconv.Atoi(m[2])
+		if mo < 1 || mo > 12 {
+			return Date{}, fmt.Errorf("invalid month in date '%v'", s)
+		}
+		return monthDate(y, time.Month(mo)), nil
+	}
+
+	for _, layout := range []string{"Jan 2006", "January 2006"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return monthDate(t.Year(), t.Month()), nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return Date{Start: t, End: t.AddDate(0, 0, 1).Add(-time.Second), Precision: Day}, nil
+	}
+	if t, err := parseISO(strings.ToUpper(s)); err == nil {
+		return ExactDate(t), nil
+	}
+	return Date{}, fmt.Errorf("unrecognized date '%v'", s)
+}
+
+func yearStart(y int) time.Time { return time.Date(y, 1, 1, 0, 0, 0, 0, time.Local) }
+
+func monthDate(y int, m time.Month) Date {
+	start := time.Date(y, m, 1, 0, 0, 0, 0, time.Local)
+	return Date{Start: start, End: start.AddDate(0, 1, 0).Add(-time.Second), Precision: Month}
+}
@@ -10,7 +10,19 @@
 //   	- added INTEGER (unix secs since epoch)
 //   	- taken INTEGER (unix secs since epoch)
 //   	- orient INTEGER (EXIF)
-//   	- thumb BLOB (JPEG bytes)
+//   	- thumb BLOB (JPEG bytes - moved to the cache directory by Vacuum)
+//   	- precision INTEGER (how accurately taken is known - see Precision)
+//   	- takenend INTEGER (unix secs since epoch - end of an approximate date)
+//   	- rating INTEGER (0 through 5 stars)
+//   	- flag INTEGER (-1 rejected, 0 unflagged, 1 picked)
+//   	- label TEXT (color label)
+//   	- deleted INTEGER (unix secs when moved to the trash, 0 if not deleted)
+//   	- hidden INTEGER (1 for private pics excluded from default listings)
+//   	- checked INTEGER (unix secs when the file was last verified)
+//   	- checkerr TEXT (error from the last verification, empty if it passed)
+//   	- thumbkey TEXT (name of the thumbnail in the cache directory)
+//   	- stack INTEGER (id of the top pic of the pic's stack, 0 if unstacked)
+//   	- parent INTEGER (id of the pic this is an edited version of, 0 if none)
 //   * meta
 //   	- id INTEGER (key into files table id)
 //   	- time INTEGER (unix secs since epoch)
@@ -0,0 +1,289 @@
+package piclib
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"os"
+
+	"github.com/disintegration/imaging"
+)
+
+// Edit operations that can be stacked non-destructively on a pic.
+const (
+	OpRotate     = "rotate"     // Amount: degrees clockwise (multiple of 90)
+	OpStraighten = "straighten" // Amount: degrees clockwise (small, free angle)
+	OpCrop       = "crop"       // X, Y, W, H: fractions of the image size
+	OpFlip       = "flip"       // Dir: "h" or "v"
+	OpExposure   = "exposure"   // Amount: exposure change in stops (EV)
+	OpContrast   = "contrast"   // Amount: percent change (-100 to 100)
+)
+
+// Edit is a single operation in a pic's edit stack.  Edits are applied in
+// order on top of the original (EXIF-oriented) image.
+type Edit struct {
+	Op         string
+	Amount     float64 `json:",omitempty"`
+	X, Y, W, H float64 `json:",omitempty"`
+	Dir        string  `json:",omitempty"`
+}
+
+func (e Edit) String() string {
+	switch e.Op {
+	case OpCrop:
+		return fmt.Sprintf("%v %g,%g,%g,%g", e.Op, e.X, e.Y, e.W, e.H)
+	case OpFlip:
+		return fmt.Sprintf("%v %v", e.Op, e.Dir)
+	}
+	return fmt.Sprintf("%v %g", e.Op, e.Amount)
+}
+
+func (e Edit) validate() error {
+	switch e.Op {
+	case OpRotate:
+		if math.Mod(e.Amount, 90) != 0 {
+			return fmt.Errorf("rotation must be a multiple of 90 degrees (got %v)", e.Amount)
+		}
+	case OpStraighten:
+		if math.Abs(e.Amount) > 45 {
+			return fmt.Errorf("straighten angle must be between -45 and 45 degrees (got %v)", e.Amount)
+		}
+	case OpCrop:
+		if e.X < 0 || e.Y < 0 || e.W <= 0 || e.H <= 0 || e.X+e.W > 1 || e.Y+e.H > 1 {
+			return fmt.Errorf("invalid crop rectangle %v,%v,%v,%v", e.X, e.Y, e.W, e.H)
+		}
+	case OpFlip:
+		if e.Dir != "h" && e.Dir != "v" {
+			return fmt.Errorf("flip direction must be 'h' or 'v' (got '%v')", e.Dir)
+		}
+	case OpExposure, OpContrast:
+	default:
+		return fmt.Errorf("unknown edit operation '%v'", e.Op)
+	}
+	return nil
+}
+
+// Apply performs the edit on img.
+func (e Edit) Apply(img image.Image) image.Image {
+	switch e.Op {
+	case OpRotate:
+		switch int(math.Mod(math.Mod(e.Amount, 360)+360, 360)) {
+		case 90:
+			return imaging.Rotate270(img)
+		case 180:
+			return imaging.Rotate180(img)
+		case 270:
+			return imaging.Rotate90(img)
+		}
+	case OpStraighten:
+		return straighten(img, e.Amount)
+	case OpCrop:
+		b := img.Bounds()
+		w, h := float64(b.Dx()), float64(b.Dy())
+		r := image.Rect(int(e.X*w), int(e.Y*h), int((e.X+e.W)*w), int((e.Y+e.H)*h))
+		// tiny crops would otherwise round to an empty image that can't be encoded
+		if r.Dx() < 1 {
+			r.Max.X = r.Min.X + 1
+		}
+		if r.Dy() < 1 {
+			r.Max.Y = r.Min.Y + 1
+		}
+		return imaging.Crop(img, r.Add(b.Min))
+	case OpFlip:
+		if e.Dir == "v" {
+			return imaging.FlipV(img)
+		}
+		return imaging.FlipH(img)
+	case OpExposure:
+		mult := math.Pow(2, e.Amount)
+		return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+			return color.NRGBA{scale8(c.R, mult), scale8(c.G, mult), scale8(c.B, mult), c.A}
+		})
+	case OpContrast:
+		return imaging.AdjustContrast(img, e.Amount)
+	}
+	return img
+}
+
+func scale8(v uint8, mult float64) uint8 {
+	return uint8(math.Min(255, float64(v)*mult+0.5))
+}
+
+// straighten rotates img clockwise by deg degrees and crops the result to the
+// largest rectangle with the original aspect ratio that contains no blank
+// corners.
+func straighten(img image.Image, deg float64) image.Image {
+	src := imaging.Clone(img)
+	w, h := float64(src.Bounds().Dx()), float64(src.Bounds().Dy())
+	theta := deg * math.Pi / 180
+	sin, cos := math.Sin(theta), math.Cos(theta)
+	as, ac := math.Abs(sin), math.Abs(cos)
+	k := math.Min(w/(w*ac+h*as), h/(w*as+h*ac))
+
+	dw, dh := int(k*w), int(k*h)
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			// map destination pixel center back into the source image
+			u, v := float64(x)+0.5-float64(dw)/2, float64(y)+0.5-float64(dh)/2
+			sx := u*cos + v*sin + w/2 - 0.5
+			sy := -u*sin + v*cos + h/2 - 0.5
+			dst.SetNRGBA(x, y, bilinear(src, sx, sy))
+		}
+	}
+	return dst
+}
+
+func bilinear(img *image.NRGBA, x, y float64) color.NRGBA {
+	b := img.Bounds()
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	at := func(x, y int) color.NRGBA {
+		x = int(math.Max(0, math.Min(float64(b.Dx()-1), float64(x))))
+		y = int(math.Max(0, math.Min(float64(b.Dy()-1), float64(y))))
+		return img.NRGBAAt(b.Min.X+x, b.Min.Y+y)
+	}
+	c00, c10, c01, c11 := at(x0, y0), at(x0+1, y0), at(x0, y0+1), at(x0+1, y0+1)
+	mix := func(a, b, c, d uint8) uint8 {
+		top := float64(a)*(1-fx) + float64(b)*fx
+		bot := float64(c)*(1-fx) + float64(d)*fx
+		return uint8(top*(1-fy) + bot*fy + 0.5)
+	}
+	return color.NRGBA{
+		mix(c00.R, c10.R, c01.R, c11.R),
+		mix(c00.G, c10.G, c01.G, c11.G),
+		mix(c00.B, c10.B, c01.B, c11.B),
+		mix(c00.A, c10.A, c01.A, c11.A),
+	}
+}
+
+// ApplyEdits performs each edit in order on img.
+func ApplyEdits(img image.Image, edits []Edit) image.Image {
+	for _, e := range edits {
+		img = e.Apply(img)
+	}
+	return img
+}
+
+// Edits returns the pic's edit stack.
+func (p *Pic) Edits() ([]Edit, error) {
+	val, err := p.GetMeta(EditsField)
+	if err != nil || val == "" {
+		return nil, err
+	}
+	var edits []Edit
+	if err := json.Unmarshal([]byte(val), &edits); err != nil {
+		return nil, fmt.Errorf("pic %v has a malformed edit stack: %v", p.id, err)
+	}
+	return edits, nil
+}
+
+// SetEdits replaces the pic's edit stack and regenerates its thumbnail.
+// Previous stacks remain in the pic's meta history.
+func (p *Pic) SetEdits(edits []Edit) error {
+	for _, e := range edits {
+		if err := e.validate(); err != nil {
+			return err
+		}
+	}
+
+	val := ""
+	if len(edits) > 0 {
+		data, err := json.Marshal(edits)
+		if err != nil {
+			return err
+		}
+		val = string(data)
+	}
+	if err := p.SetMeta(EditsField, val); err != nil {
+		return err
+	}
+	return p.RebuildThumb()
+}
+
+// AddEdit pushes e onto the pic's edit stack.
+func (p *Pic) AddEdit(e Edit) error {
+	edits, err := p.Edits()
+	if err != nil {
+		return err
+	}
+	return p.SetEdits(append(edits, e))
+}
+
+// UndoEdit removes the most recent edit from the pic's edit stack.
+func (p *Pic) UndoEdit() error {
+	edits, err := p.Edits()
+	if err != nil || len(edits) == 0 {
+		return err
+	}
+	return p.SetEdits(edits[:len(edits)-1])
+}
+
+// RevertEdits clears the pic's edit stack, restoring the original.
+func (p *Pic) RevertEdits() error { return p.SetEdits(nil) }
+
+// Image decodes the pic's original file (or the embedded preview of a raw
+// file) and applies its orientation and edit stack.
+// Images over the library's MaxPixels are refused.
+func (p *Pic) Image() (image.Image, error) {
+	img, release, err := p.image()
+	if err != nil {
+		return nil, err
+	}
+	release()
+	return img, nil
+}
+
+// image is like Image but holds the decoding memory used from the library's
+// budget until release is called.
+func (p *Pic) image() (img image.Image, release func(), err error) {
+	edits, err := p.Edits()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(p.Filepath())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	// orientation and edits may each copy the image
+	extra := int64(0)
+	if p.Orient > 1 {
+		extra += 4
+	}
+	if len(edits) > 0 {
+		extra += 4
+	}
+	img, release, err = p.lib.decodeBounded(p.Name, f, extra)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ApplyEdits(orientImage(img, p.Orient), edits), release, nil
+}
+
+// Render writes the pic as a JPEG with its edits applied, scaled to fit
+// within w by h pixels.  If w and h are both zero, the full size is used.
+func (p *Pic) Render(dst io.Writer, w, h int) error {
+	img, release, err := p.image()
+	if err != nil {
+		return err
+	}
+	defer release()
+	if w != 0 || h != 0 {
+		b := img.Bounds()
+		if w == 0 {
+			w = b.Dx() * h / b.Dy()
+		} else if h == 0 {
+			h = b.Dy() * w / b.Dx()
+		}
+		img = imaging.Fit(img, w, h, imaging.Lanczos)
+	}
+	return jpeg.Encode(dst, img, &jpeg.Options{Quality: 90})
+}
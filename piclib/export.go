@@ -0,0 +1,267 @@
+package piclib
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Archive formats supported by Export.
+const (
+	ZipFormat = "zip"
+	TarFormat = "tar"
+)
+
+// DefaultExportName names archive entries the same way the copy command names
+// copied files.
+const DefaultExportName = "{name}-{id}{ext}"
+
+// ExportOptions configure how Export writes pics into an archive.
+type ExportOptions struct {
+	Format string // ZipFormat (default) or TarFormat
+	// Name is a template for each entry's path in the archive.  The
+	// placeholders {name} (original name without extension), {ext}, {id},
+	// {date} (YYYY-MM-DD), {year}, {month} and {sum} (short hash) are
+	// replaced for each pic.  Defaults to DefaultExportName.
+	Name string
+	// Manifest is "json" or "csv" to include a manifest of each pic's notes,
+	// tags, dates and ratings.  Empty for no manifest.
+	Manifest string
+	// Orig causes original files to be archived instead of rendering edited
+	// or re-oriented pics.
+	Orig bool
+}
+
+// ManifestEntry describes one exported pic in an archive's manifest.
+type ManifestEntry struct {
+	Id     int
+	Path   string // entry path within the archive
+	Name   string // original file name
+	Taken  string
+	Notes  string
+	Tags   []string
+	Rating int
+	Flag   string
+	Label  string
+}
+
+var manifestCols = []string{"id", "path", "name", "taken", "notes", "tags", "rating", "flag", "label"}
+
+func (m ManifestEntry) row() []string {
+	return []string{strconv.Itoa(m.Id), m.Path, m.Name, m.Taken, m.Notes,
+		strings.Join(m.Tags, ","), strconv.Itoa(m.Rating), m.Flag, m.Label}
+}
+
+// archiver abstracts over the zip and tar writers.
+type archiver interface {
+	// add writes an entry of size bytes read from r.
+	add(name string, modtime time.Time, size int64, r io.Reader) error
+	Close() error
+}
+
+type zipArchiver struct{ *zip.Writer }
+
+func (z zipArchiver) add(name string, modtime time.Time, size int64, r io.Reader) error {
+	hdr := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modtime}
+	if isImage(name) { // already compressed
+		hdr.Method = zip.Store
+	}
+	w, err := z.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+type tarArchiver struct{ *tar.Writer }
+
+func (t tarArchiver) add(name string, modtime time.Time, size int64, r io.Reader) error {
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: size, ModTime: modtime, Typeflag: tar.TypeReg}
+	if err := t.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := io.Copy(t, r)
+	return err
+}
+
+func isImage(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".mp4", ".mov", ".m4v", ".avi":
+		return true
+	}
+	return false
+}
+
+// Validate checks that the archive and manifest formats are supported, so
+// callers can reject bad options before writing anything.
+func (opts ExportOptions) Validate() error {
+	switch opts.Format {
+	case ZipFormat, TarFormat, "":
+	default:
+		return fmt.Errorf("unsupported archive format '%v'", opts.Format)
+	}
+	if opts.Manifest != "" && opts.Manifest != "json" && opts.Manifest != "csv" {
+		return fmt.Errorf("unsupported manifest format '%v'", opts.Manifest)
+	}
+	return nil
+}
+
+// Export streams pics into a ZIP or tar archive written to w.  Nothing is
+// staged on disk, so w can be e.g. an http.ResponseWriter.
+func Export(w io.Writer, pics []*Pic, opts ExportOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	var a archiver = zipArchiver{zip.NewWriter(w)}
+	if opts.Format == TarFormat {
+		a = tarArchiver{tar.NewWriter(w)}
+	}
+	tmpl := opts.Name
+	if tmpl == "" {
+		tmpl = DefaultExportName
+	}
+
+	var manifest []ManifestEntry
+	used := map[string]bool{}
+	for _, p := range pics {
+		mod, err := p.Modified()
+		if err != nil {
+			return err
+		}
+		render := mod && !opts.Orig
+
+		ext := path.Ext(p.Name)
+		if render {
+			ext = ".jpg"
+		}
+		name := uniqueName(ExportName(p, tmpl, ext), used)
+
+		if render {
+			var buf bytes.Buffer
+			if err := p.Render(&buf, 0, 0); err != nil {
+				return err
+			}
+			err = a.add(name, p.Taken, int64(buf.Len()), &buf)
+		} else {
+			err = p.addOrig(a, name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if opts.Manifest != "" {
+			m, err := p.manifestEntry(name)
+			if err != nil {
+				return err
+			}
+			manifest = append(manifest, m)
+		}
+	}
+
+	if opts.Manifest != "" {
+		var buf bytes.Buffer
+		if err := writeManifest(&buf, manifest, opts.Manifest); err != nil {
+			return err
+		}
+		if err := a.add("manifest."+opts.Manifest, time.Now(), int64(buf.Len()), &buf); err != nil {
+			return err
+		}
+	}
+	return a.Close()
+}
+
+func (p *Pic) addOrig(a archiver, name string) error {
+	f, err := os.Open(p.Filepath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return a.add(name, p.Taken, info.Size(), f)
+}
+
+// ExportName expands the name template tmpl (see ExportOptions) for p.  ext is
+// the extension of the file being written and is appended if tmpl doesn't
+// contain {ext}.
+func ExportName(p *Pic, tmpl, ext string) string {
+	base := strings.TrimSuffix(p.Name, path.Ext(p.Name))
+	r := strings.NewReplacer(
+		"{name}", base,
+		"{ext}", ext,
+		"{id}", strconv.Itoa(p.Id),
+		"{date}", p.Taken.Format("2006-01-02"),
+		"{year}", p.Taken.Format("2006"),
+		"{month}", p.Taken.Format("01"),
+		"{sum}", fmt.Sprintf("%x", p.Sum)[:12],
+	)
+	name := path.Clean("/" + r.Replace(tmpl))[1:] // no escaping the archive root
+	if !strings.Contains(tmpl, "{ext}") {
+		name += ext
+	}
+	return name
+}
+
+// uniqueName appends a counter to name if it has already been used.
+func uniqueName(name string, used map[string]bool) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%v-%v%v", base, i, ext)
+	}
+	used[name] = true
+	return name
+}
+
+func (p *Pic) manifestEntry(name string) (ManifestEntry, error) {
+	m := ManifestEntry{
+		Id:     p.Id,
+		Path:   name,
+		Name:   p.Name,
+		Taken:  p.When().String(),
+		Rating: p.Rating,
+		Label:  p.Label,
+	}
+	if p.Flag != Unflagged {
+		m.Flag = p.Flag.String()
+	}
+
+	var err error
+	if m.Notes, err = p.GetNotes(); err != nil {
+		return m, err
+	}
+	m.Tags, err = p.Tags()
+	return m, err
+}
+
+func writeManifest(w io.Writer, entries []ManifestEntry, format string) error {
+	if format == "json" {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(manifestCols)
+	for _, m := range entries {
+		cw.Write(m.row())
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,137 @@
+package piclib
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// Format describes a kind of file that can be stored in a library.
+type Format struct {
+	Name string
+	Exts []string // lower case file extensions including the dot
+	// Decode decodes a file's image.  It is nil for formats without images
+	// to show (such as videos).
+	Decode func(r io.Reader) (image.Image, error)
+	// DecodeConfig returns the dimensions of a file's image without decoding
+	// it, which allows limiting the memory used to decode large images.
+	DecodeConfig func(r io.Reader) (image.Config, error)
+	// Unsupported explains why images in this format can't be shown (empty
+	// if they can be).  Such files are still imported, with a warning, but
+	// have no thumbnails.
+	Unsupported string
+}
+
+var formats = map[string]*Format{} // by extension
+
+// RegisterFormat makes a file format known to piclib, replacing any format
+// previously registered for the same extensions.
+func RegisterFormat(f Format) {
+	for _, ext := range f.Exts {
+		formats[strings.ToLower(ext)] = &f
+	}
+}
+
+// LookupFormat returns the format of the file called name based on its
+// extension.
+func LookupFormat(name string) (*Format, bool) {
+	f, ok := formats[strings.ToLower(filepath.Ext(name))]
+	return f, ok
+}
+
+func init() {
+	RegisterFormat(Format{Name: "jpeg", Exts: []string{".jpg", ".jpeg", ".jpe"}, Decode: jpeg.Decode, DecodeConfig: jpeg.DecodeConfig})
+	RegisterFormat(Format{Name: "png", Exts: []string{".png"}, Decode: png.Decode, DecodeConfig: png.DecodeConfig})
+	RegisterFormat(Format{Name: "gif", Exts: []string{".gif"}, Decode: gif.Decode, DecodeConfig: gif.DecodeConfig})
+	// multi-page tiffs decode to their first page
+	RegisterFormat(Format{Name: "tiff", Exts: []string{".tif", ".tiff"}, Decode: tiff.Decode, DecodeConfig: tiff.DecodeConfig})
+	RegisterFormat(Format{Name: "bmp", Exts: []string{".bmp"}, Decode: bmp.Decode, DecodeConfig: bmp.DecodeConfig})
+	RegisterFormat(Format{Name: "webp", Exts: []string{".webp"}, Decode: webp.Decode, DecodeConfig: webp.DecodeConfig})
+	RegisterFormat(Format{
+		Name:        "heif",
+		Exts:        []string{".heic", ".heif", ".avif"},
+		Unsupported: "heif images can't be decoded",
+	})
+	RegisterFormat(Format{Name: "video", Exts: []string{
+		".3g2", ".3gp", ".avi", ".flv", ".m2ts", ".m4v", ".mkv", ".mov", ".mp4",
+		".mpeg", ".mpg", ".mts", ".ogv", ".webm", ".wmv",
+	}})
+
+	var exts []string
+	for ext := range rawExts {
+		exts = append(exts, ext)
+	}
+	RegisterFormat(Format{Name: "raw", Exts: exts, Decode: decodeRaw, DecodeConfig: decodeRawConfig})
+	RegisterFormat(Format{
+		Name:        "raw",
+		Exts:        otherRawExts,
+		Unsupported: "previews can't be extracted from CR3, CRW and X3F raw files",
+	})
+}
+
+// UnsupportedErr is returned when decoding the image of a file that piclib
+// can't show.
+type UnsupportedErr struct {
+	Name   string
+	Reason string
+}
+
+func (e UnsupportedErr) Error() string {
+	return fmt.Sprintf("%v has an unsupported format: %v", e.Name, e.Reason)
+}
+
+func IsUnsupported(err error) bool {
+	_, ok := err.(UnsupportedErr)
+	return ok
+}
+
+// formatWarning returns why the file called name with content r will have no
+// thumbnail if it isn't in a format that can be shown (empty otherwise).
+// Files with unknown extensions can be shown if their content is in a
+// registered image format.  Formats without images such as videos aren't
+// warned about.
+func formatWarning(name string, r io.ReadSeeker) (string, error) {
+	if f, ok := LookupFormat(name); ok {
+		return f.Unsupported, nil
+	}
+
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return "", err
+	}
+	if _, _, err := image.DecodeConfig(r); err != nil {
+		return "unrecognized file type", nil
+	}
+	return "", nil
+}
+
+// hasImage returns false for files known to have no image to decode.
+func hasImage(name string) bool {
+	f, ok := LookupFormat(name)
+	return !ok || f.Decode != nil
+}
+
+// decodeImage decodes the image of the file called name from r.
+func decodeImage(name string, r io.Reader) (image.Image, error) {
+	f, ok := LookupFormat(name)
+	if !ok {
+		img, _, err := image.Decode(r)
+		return img, err
+	} else if f.Decode == nil {
+		reason := f.Unsupported
+		if reason == "" {
+			reason = "no image to decode in " + f.Name + " files"
+		}
+		return nil, UnsupportedErr{name, reason}
+	}
+	return f.Decode(r)
+}
@@ -0,0 +1,114 @@
+package piclib
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// TrackPoint is a single timestamped position from a GPS track log.
+type TrackPoint struct {
+	Time time.Time
+	Lat  float64
+	Lon  float64
+	Ele  float64
+}
+
+// Track is a time-ordered list of track points.
+type Track []TrackPoint
+
+func (t Track) Len() int           { return len(t) }
+func (t Track) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t Track) Less(i, j int) bool { return t[i].Time.Before(t[j].Time) }
+
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Ele  float64 `xml:"ele"`
+	Time string  `xml:"time"`
+}
+
+type gpxFile struct {
+	Tracks []struct {
+		Segs []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+}
+
+// ParseGPX reads all timestamped track and route points from a GPX file.
+// Points without a time are ignored.  The returned track is sorted by time.
+func ParseGPX(r io.Reader) (Track, error) {
+	var g gpxFile
+	if err := xml.NewDecoder(r).Decode(&g); err != nil {
+		return nil, err
+	}
+
+	var pts []gpxPoint
+	for _, trk := range g.Tracks {
+		for _, seg := range trk.Segs {
+			pts = append(pts, seg.Points...)
+		}
+	}
+	for _, rte := range g.Routes {
+		pts = append(pts, rte.Points...)
+	}
+
+	var t Track
+	for _, pt := range pts {
+		if pt.Time == "" {
+			continue
+		}
+		tm, err := time.Parse(time.RFC3339, pt.Time)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gpx time '%v': %v", pt.Time, err)
+		}
+		t = append(t, TrackPoint{Time: tm, Lat: pt.Lat, Lon: pt.Lon, Ele: pt.Ele})
+	}
+	sort.Sort(t)
+	return t, nil
+}
+
+// Locate returns the position on the track at time tm.  If tm falls between
+// two points that are both within maxgap of it, the position is linearly
+// interpolated between them.  Otherwise the nearest point is used if it is
+// within maxgap.  ok is false if no point is close enough.
+func (t Track) Locate(tm time.Time, maxgap time.Duration) (pt TrackPoint, ok bool) {
+	if len(t) == 0 {
+		return pt, false
+	}
+
+	i := sort.Search(len(t), func(i int) bool { return !t[i].Time.Before(tm) })
+	if i < len(t) && t[i].Time.Equal(tm) {
+		return t[i], true
+	}
+
+	var before, after *TrackPoint
+	if i > 0 && tm.Sub(t[i-1].Time) <= maxgap {
+		before = &t[i-1]
+	}
+	if i < len(t) && t[i].Time.Sub(tm) <= maxgap {
+		after = &t[i]
+	}
+
+	switch {
+	case before != nil && after != nil:
+		frac := float64(tm.Sub(before.Time)) / float64(after.Time.Sub(before.Time))
+		return TrackPoint{
+			Time: tm,
+			Lat:  before.Lat + frac*(after.Lat-before.Lat),
+			Lon:  before.Lon + frac*(after.Lon-before.Lon),
+			Ele:  before.Ele + frac*(after.Ele-before.Ele),
+		}, true
+	case before != nil:
+		return *before, true
+	case after != nil:
+		return *after, true
+	}
+	return pt, false
+}
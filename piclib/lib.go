@@ -4,8 +4,11 @@ import (
 	"database/sql"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/rwcarlsen/go-sqlite3"
@@ -15,7 +18,16 @@ import (
 const (
 	Version    = "0.1"
 	Libname    = "piclib.sqlite"
+	TrashDir   = "trash"
 	NotesField = "Notes"
+	GPSField   = "GPS"
+	TakenField = "Taken"
+	TagsField  = "Tags"
+	EditsField = "Edits"
+	// OrientField records manual overrides of a pic's EXIF orientation.
+	OrientField = "Orient"
+	// TakenSourceField records which DateSource provided a pic's taken time.
+	TakenSourceField = "TakenSource"
 )
 
 const thumbw, thumbh = 1000, 0
@@ -24,6 +36,43 @@ type Lib struct {
 	Path           string
 	db             *sql.DB
 	ThumbW, ThumbH int
+	// DateSources are tried in order to determine when added pics were
+	// taken.  If nil, DefaultDateSources is used.
+	DateSources []DateSource
+	// IncludeHidden causes listings to return hidden pics.
+	IncludeHidden bool
+	// MaxPixels is the largest image (in pixels) that will be decoded.  If
+	// zero, DefaultMaxPixels is used.
+	MaxPixels int
+	// DecodeMemory is an estimate of the memory in bytes that concurrent
+	// image decodes may use in total.  Decodes wait until enough is free.
+	// If zero, DefaultDecodeMemory is used.  It must be set before any
+	// images are decoded.
+	DecodeMemory int64
+	// Warn is called with problems that don't prevent pics from being
+	// added, such as malformed metadata.  If nil, they are logged.
+	Warn func(msg string)
+
+	budgetOnce sync.Once
+	budget     *memBudget
+	addMu      sync.Mutex
+	adding     map[string]bool // checksums of files being added
+}
+
+func (l *Lib) warnf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if l.Warn != nil {
+		l.Warn(msg)
+		return
+	}
+	log.Printf("[WARN] %v\n", msg)
+}
+
+func (l *Lib) thumbSize() (w, h int) {
+	if l.ThumbW == 0 && l.ThumbH == 0 {
+		return thumbw, thumbh
+	}
+	return l.ThumbW, l.ThumbH
 }
 
 func Open(path string) (*Lib, error) {
@@ -48,6 +97,10 @@ func Open(path string) (*Lib, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = addColumns(db, "files", filesCols)
+	if err != nil {
+		return nil, err
+	}
 	_, err = db.Exec("CREATE INDEX IF NOT EXISTS files_taken ON files (taken,id,sum,name,added,orient);")
 	if err != nil {
 		return nil, err
@@ -60,43 +113,129 @@ func Open(path string) (*Lib, error) {
 	if err != nil {
 		return nil, err
 	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS files_rating ON files (rating,flag,label,taken);")
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS files_flag ON files (flag,taken);")
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS files_label ON files (label,taken);")
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS files_checked ON files (checked,id);")
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS files_stack ON files (stack,id);")
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS files_parent ON files (parent,id);")
+	if err != nil {
+		return nil, err
+	}
 	return &Lib{Path: path, db: db}, nil
 }
 
-func (l *Lib) Open(id int) (*Pic, error) {
-	s := "SELECT id,sum,name,added,taken,orient FROM files WHERE id=?"
+// filesCols are columns added to the files table after its original schema.
+// They are added to older libraries when they are opened.
+var filesCols = []string{
+	"precision INTEGER DEFAULT 0",
+	"takenend INTEGER",
+	"rating INTEGER DEFAULT 0",
+	"flag INTEGER DEFAULT 0",
+	"label TEXT DEFAULT ''",
+	"deleted INTEGER DEFAULT 0",
+	"hidden INTEGER DEFAULT 0",
+	"checked INTEGER DEFAULT 0",
+	"checkerr TEXT DEFAULT ''",
+	"thumbkey TEXT DEFAULT ''",
+	"stack INTEGER DEFAULT 0",
+	"parent INTEGER DEFAULT 0",
+}
+
+func addColumns(db *sql.DB, table string, cols []string) error {
+	rows, err := db.Query("PRAGMA table_info(" + table + ");")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	have := map[string]bool{}
+	for rows.Next() {
+		var cid, notnull, pk int
+		var name, typ string
+		var dflt interface{}
+		if err := rows.Scan(&cid, &name, &typ, &notnull, &dflt, &pk); err != nil {
+			return err
+		}
+		have[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, col := range cols {
+		if have[strings.Fields(col)[0]] {
+			continue
+		}
+		_, err := db.Exec("ALTER TABLE " + table + " ADD COLUMN " + col + ";")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// piccols are the files table columns scanned by scanPic.
+const piccols = "id,sum,name,added,taken,orient,precision,takenend,rating,flag,label,deleted,hidden,checked,checkerr,stack,parent"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (l *Lib) scanPic(row scanner) (*Pic, error) {
 	p := &Pic{lib: l}
-	var taken, added int64
-	err := l.db.QueryRow(s, id).Scan(&p.id, &p.Sum, &p.Name, &added, &taken, &p.Orient)
+	var added, taken int64
+	var takenend sql.NullInt64
+	var deleted, checked int64
+	err := row.Scan(&p.id, &p.Sum, &p.Name, &added, &taken, &p.Orient, &p.Precision, &takenend, &p.Rating, &p.Flag, &p.Label, &deleted, &p.Hidden, &checked, &p.CheckErr, &p.Stack, &p.Parent)
 	if err != nil {
 		return nil, err
 	}
 
+	p.Id = p.id
 	p.Taken = time.Unix(taken, 0)
 	p.Added = time.Unix(added, 0)
-	p.Id = p.id
+	p.TakenEnd = p.Taken
+	if takenend.Valid {
+		p.TakenEnd = time.Unix(takenend.Int64, 0)
+	}
+	if deleted != 0 {
+		p.Deleted = time.Unix(deleted, 0)
+	}
+	if checked != 0 {
+		p.Checked = time.Unix(checked, 0)
+	}
 	return p, nil
 }
 
-func (l *Lib) ListTime(start, end time.Time) (pics []*Pic, err error) {
-	s := "SELECT id,sum,name,added,taken,orient FROM files"
-	s += " WHERE taken >= ? AND taken <= ? ORDER BY taken DESC;"
-	rows, err := l.db.Query(s, start.Unix(), end.Unix())
+func (l *Lib) queryPics(s string, args ...interface{}) (pics []*Pic, err error) {
+	rows, err := l.db.Query(s, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var added, taken int64
 	for rows.Next() {
-		p := &Pic{lib: l}
-		err := rows.Scan(&p.id, &p.Sum, &p.Name, &added, &taken, &p.Orient)
+		p, err := l.scanPic(rows)
 		if err != nil {
 			return nil, err
 		}
-		p.Id = p.id
-		p.Taken = time.Unix(taken, 0)
-		p.Added = time.Unix(added, 0)
 		pics = append(pics, p)
 	}
 	if err := rows.Err(); err != nil {
@@ -105,37 +244,53 @@ func (l *Lib) ListTime(start, end time.Time) (pics []*Pic, err error) {
 	return pics, nil
 }
 
+// visible is the condition selecting pics that are shown by default listings.
+func (l *Lib) visible() string {
+	if l.IncludeHidden {
+		return "deleted=0"
+	}
+	return "deleted=0 AND hidden=0"
+}
+
+// Open returns the pic with the given id - even if it is in the trash.
+func (l *Lib) Open(id int) (*Pic, error) {
+	s := "SELECT " + piccols + " FROM files WHERE id=?"
+	return l.scanPic(l.db.QueryRow(s, id))
+}
+
+// ListTime returns pics taken between start and end, most recent first.  Pics
+// with approximate dates are included if their date range overlaps.
+func (l *Lib) ListTime(start, end time.Time) (pics []*Pic, err error) {
+	s := "SELECT " + piccols + " FROM files"
+	s += " WHERE " + l.visible() + " AND taken <= ? AND COALESCE(takenend,taken) >= ? ORDER BY taken DESC;"
+	return l.queryPics(s, end.Unix(), start.Unix())
+}
+
 func (l *Lib) List(limit, offset int) (pics []*Pic, err error) {
-	s := "SELECT id,sum,name,added,taken,orient FROM files ORDER BY taken DESC,added DESC"
-	var rows *sql.Rows
+	s := "SELECT " + piccols + " FROM files WHERE " + l.visible() + " ORDER BY taken DESC,added DESC"
 	if limit > 0 {
 		s += " LIMIT ? OFFSET ?"
-		rows, err = l.db.Query(s, limit, offset)
-	} else {
-		rows, err = l.db.Query(s)
+		return l.queryPics(s, limit, offset)
 	}
+	return l.queryPics(s)
+}
 
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// ListRated returns pics rated at least min stars, most recent first.
+func (l *Lib) ListRated(min int) (pics []*Pic, err error) {
+	s := "SELECT " + piccols + " FROM files WHERE " + l.visible() + " AND rating >= ? ORDER BY taken DESC;"
+	return l.queryPics(s, min)
+}
 
-	var added, taken int64
-	for rows.Next() {
-		p := &Pic{lib: l}
-		err := rows.Scan(&p.id, &p.Sum, &p.Name, &added, &taken, &p.Orient)
-		if err != nil {
-			return nil, err
-		}
-		p.Id = p.id
-		p.Taken = time.Unix(taken, 0)
-		p.Added = time.Unix(added, 0)
-		pics = append(pics, p)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return pics, nil
+// ListFlagged returns pics with the given pick/reject flag, most recent first.
+func (l *Lib) ListFlagged(f Flag) (pics []*Pic, err error) {
+	s := "SELECT " + piccols + " FROM files WHERE " + l.visible() + " AND flag=? ORDER BY taken DESC;"
+	return l.queryPics(s, f)
+}
+
+// ListLabel returns pics with the given color label, most recent first.
+func (l *Lib) ListLabel(label string) (pics []*Pic, err error) {
+	s := "SELECT " + piccols + " FROM files WHERE " + l.visible() + " AND label=? ORDER BY taken DESC;"
+	return l.queryPics(s, label)
 }
 
 func diskname(name string, sum []byte) string {
@@ -162,6 +317,27 @@ func (l *Lib) Exists(sum []byte) (exists bool, err error) {
 	return true, nil
 }
 
+// startAdd marks a file with the given checksum as being added, returning
+// false if another goroutine is already adding it.
+func (l *Lib) startAdd(sum []byte) bool {
+	l.addMu.Lock()
+	defer l.addMu.Unlock()
+	if l.adding == nil {
+		l.adding = map[string]bool{}
+	}
+	if l.adding[string(sum)] {
+		return false
+	}
+	l.adding[string(sum)] = true
+	return true
+}
+
+func (l *Lib) endAdd(sum []byte) {
+	l.addMu.Lock()
+	delete(l.adding, string(sum))
+	l.addMu.Unlock()
+}
+
 // Add copies the picture into and adds it to the current library
 func (l *Lib) AddFile(pic string) (p *Pic, err error) {
 	// check if file exists
@@ -175,10 +351,29 @@ func (l *Lib) AddFile(pic string) (p *Pic, err error) {
 		return nil, err
 	}
 
-	if exists, err := l.Exists(sum); err == nil && exists {
-		return nil, DupErr{pic}
-	} else if err != nil {
+	if !l.startAdd(sum) {
+		return nil, DupErr{pic: pic}
+	}
+	defer l.endAdd(sum)
+	var id int
+	var deleted int64
+	err = l.db.QueryRow("SELECT id,deleted FROM files WHERE sum=?;", sum).Scan(&id, &deleted)
+	if err == nil {
+		return nil, DupErr{pic: pic, id: id, trashed: deleted != 0}
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	warning, err := formatWarning(pic, f)
+	if err != nil {
 		return nil, err
+	} else if warning != "" {
+		l.warnf("%v: %v - imported without a thumbnail", pic, warning)
+	}
+
+	xmp, err := readXMP(pic, f)
+	if err != nil {
+		l.warnf("%v: %v", pic, err)
 	}
 
 	// copy file into library
@@ -204,7 +399,6 @@ func (l *Lib) AddFile(pic string) (p *Pic, err error) {
 
 	// get meta data and make thumb
 	added := time.Now()
-	taken := time.Time{}
 	orient := 0
 
 	_, err = f.Seek(0, os.SEEK_SET)
@@ -212,13 +406,8 @@ func (l *Lib) AddFile(pic string) (p *Pic, err error) {
 		return nil, err
 	}
 
-	x, err := exif.Decode(f)
+	x, err := decodeExif(pic, f)
 	if err == nil {
-		tm, err := x.DateTime()
-		if err == nil {
-			taken = tm
-		}
-
 		tag, err := x.Get(exif.Orientation)
 		if err == nil {
 			v, _ := tag.Int(0)
@@ -226,38 +415,66 @@ func (l *Lib) AddFile(pic string) (p *Pic, err error) {
 		}
 	}
 
+	taken, datesrc, err := l.InferTaken(pic, f)
+	if err != nil {
+		taken = time.Time{}
+	}
+
 	f3, err := os.Open(pic)
 	if err != nil {
 		return nil, err
 	}
 	defer f3.Close()
 
-	w, h := l.ThumbW, l.ThumbH
-	if w == 0 && h == 0 {
-		w, h = thumbw, thumbh
+	thumb, err := l.thumbnail(pic, f3, orient)
+	if err != nil && warning == "" && hasImage(pic) {
+		l.warnf("%v: imported without a thumbnail: %v", pic, err)
+	}
+	thumbkey, err := l.cacheThumb(thumb)
+	if err != nil {
+		return nil, err
 	}
-	thumb, _ := MakeThumb(f3, w, h, orient)
 
 	// store meta data in db and return new Pic
-	sql := "INSERT INTO files (sum, name, added, taken, orient, thumb) VALUES (?,?,?,?,?,?);"
-	_, err = l.db.Exec(sql, sum, filepath.Base(pic), added.Unix(), taken.Unix(), orient, thumb)
+	sql := "INSERT INTO files (sum, name, added, taken, orient, thumbkey) VALUES (?,?,?,?,?,?);"
+	_, err = l.db.Exec(sql, sum, filepath.Base(pic), added.Unix(), taken.Unix(), orient, thumbkey)
 	if err != nil {
 		return nil, err
 	}
 
-	var id int
 	err = l.db.QueryRow("SELECT id FROM files WHERE sum=?;", sum).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
-	return l.Open(id)
+	p, err = l.Open(id)
+	if err != nil {
+		return nil, err
+	}
+	// the pic is already in the library - failing now would leave it half
+	// imported and make retries report duplicates
+	if datesrc != "" {
+		if err := p.SetMeta(TakenSourceField, datesrc); err != nil {
+			l.warnf("%v: taken date source not recorded: %v", pic, err)
+		}
+	}
+	if xmp != nil {
+		if err := p.applyXMP(xmp); err != nil {
+			l.warnf("%v: xmp metadata not fully applied: %v", pic, err)
+		}
+	}
+	return p, nil
 }
 
 type DupErr struct {
-	pic string
+	pic     string
+	id      int
+	trashed bool
 }
 
 func (s DupErr) Error() string {
+	if s.trashed {
+		return fmt.Sprintf("%v is already in the library's trash as %v - restore it with 'pics trash -restore %v'", s.pic, s.id, s.id)
+	}
 	return fmt.Sprintf("%v already exists in the library", s.pic)
 }
 
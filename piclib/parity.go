@@ -0,0 +1,524 @@
+package piclib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ParityDir is the library subdirectory holding parity files.
+const ParityDir = "parity"
+
+// DefaultRedundancy is the default size of a pic's parity data as a
+// percentage of its file's size.
+const DefaultRedundancy = 10
+
+// ErrNoParity is returned when repairing a pic that has no parity file.
+var ErrNoParity = errors.New("no parity file")
+
+const (
+	parityMagic  = "PICPAR1\n"
+	parityBlocks = 128       // max data blocks per file
+	parityWindow = 64 * 1024 // bytes of each block processed at a time
+)
+
+// Parity files protect a pic's file with Reed-Solomon erasure coding.  The
+// file is split into k equal data blocks (the last one zero padded) from
+// which m parity blocks are computed such that any k of the k+m blocks are
+// enough to reconstruct the file.  The sha256 of every block is stored so
+// damaged blocks can be identified and treated as erasures.  The layout is:
+//
+//	magic, file sum [32], file size int64, block size uint32, k uint16, m uint16,
+//	k+m block sums [32], header sum [32], m parity blocks
+type parityHeader struct {
+	Sum       [32]byte
+	Size      int64
+	BlockSize uint32
+	K, M      uint16
+	Sums      [][32]byte
+}
+
+func (h *parityHeader) len() int64 { return int64(len(parityMagic)) + 48 + int64(len(h.Sums))*32 + 32 }
+
+func (h *parityHeader) marshal() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(parityMagic)
+	buf.Write(h.Sum[:])
+	binary.Write(&buf, binary.BigEndian, h.Size)
+	binary.Write(&buf, binary.BigEndian, h.BlockSize)
+	binary.Write(&buf, binary.BigEndian, h.K)
+	binary.Write(&buf, binary.BigEndian, h.M)
+	for _, sum := range h.Sums {
+		buf.Write(sum[:])
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	buf.Write(sum[:])
+	return buf.Bytes()
+}
+
+func readParityHeader(r io.Reader) (*parityHeader, error) {
+	var buf bytes.Buffer
+	r = io.TeeReader(r, &buf)
+	magic := make([]byte, len(parityMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	} else if string(magic) != parityMagic {
+		return nil, errors.New("not a parity file")
+	}
+
+	h := &parityHeader{}
+	for _, v := range []interface{}{&h.Sum, &h.Size, &h.BlockSize, &h.K, &h.M} {
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	if h.K == 0 || h.M == 0 || int(h.K)+int(h.M) > 256 || h.BlockSize == 0 {
+		return nil, errors.New("invalid parity header")
+	}
+	h.Sums = make([][32]byte, int(h.K)+int(h.M))
+	for i := range h.Sums {
+		if _, err := io.ReadFull(r, h.Sums[i][:]); err != nil {
+			return nil, err
+		}
+	}
+
+	want := sha256.Sum256(buf.Bytes())
+	var got [32]byte
+	if _, err := io.ReadFull(r, got[:]); err != nil {
+		return nil, err
+	} else if got != want {
+		return nil, errors.New("parity header failed checksum validation")
+	}
+	return h, nil
+}
+
+// parityLayout chooses the block size and block counts protecting a file of
+// the given size with redundancy percent parity.
+func parityLayout(size int64, redundancy int) (blockSize int64, k, m int) {
+	blockSize = (size + parityBlocks - 1) / parityBlocks
+	blockSize = (blockSize + 511) / 512 * 512
+	if blockSize == 0 {
+		blockSize = 512
+	}
+	k = int((size + blockSize - 1) / blockSize)
+	if k == 0 {
+		k = 1
+	}
+	m = (k*redundancy + 99) / 100
+	if m < 1 {
+		m = 1
+	}
+	return blockSize, k, m
+}
+
+// ParityPath returns the path of the pic's parity file.
+func (p *Pic) ParityPath() string {
+	return filepath.Join(p.lib.Path, ParityDir, diskname(p.Name, p.Sum))
+}
+
+// HasParity returns true if the pic has an intact parity file for its
+// current content with at least redundancy percent parity.
+func (p *Pic) HasParity(redundancy int) bool {
+	f, err := os.Open(p.ParityPath())
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	h, err := readParityHeader(f)
+	if err != nil || !bytes.Equal(h.Sum[:], p.Sum) {
+		return false
+	}
+	_, k, m := parityLayout(h.Size, redundancy)
+	if int(h.K) != k || int(h.M) < m {
+		return false
+	}
+
+	bs, hlen := int64(h.BlockSize), h.len()
+	for j := 0; j < int(h.M); j++ {
+		sum, err := blockSum(f, hlen+int64(j)*bs, bs, hlen+int64(h.M)*bs)
+		if err != nil || sum != h.Sums[k+j] {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteParity (re)creates the pic's parity file with redundancy percent
+// parity.  The pic's file is verified first so damage isn't made permanent.
+func (p *Pic) WriteParity(redundancy int) error {
+	if redundancy < 1 || redundancy > 100 {
+		return fmt.Errorf("parity redundancy must be between 1 and 100 percent")
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	f, err := os.Open(p.Filepath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	bs, k, m := parityLayout(info.Size(), redundancy)
+	h := &parityHeader{Size: info.Size(), BlockSize: uint32(bs), K: uint16(k), M: uint16(m)}
+	copy(h.Sum[:], p.Sum)
+	h.Sums = make([][32]byte, k+m)
+	for i := 0; i < k; i++ {
+		if h.Sums[i], err = blockSum(f, int64(i)*bs, bs, h.Size); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(p.lib.Path, ParityDir), 0755); err != nil {
+		return err
+	}
+	dst := p.ParityPath()
+	tmp := dst + ".part"
+	w, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	defer w.Close()
+
+	// parity blocks are written after a header placeholder of the same size
+	hlen := h.len()
+	if _, err := w.Write(make([]byte, hlen)); err != nil {
+		return err
+	}
+
+	rs := newReedSolomon(k, m)
+	data := make([][]byte, k)
+	parity := make([][]byte, m)
+	for off := int64(0); off < bs; off += parityWindow {
+		n := min64(parityWindow, bs-off)
+		for i := range data {
+			data[i] = make([]byte, n)
+			if err := readPadded(f, data[i], int64(i)*bs+off, h.Size); err != nil {
+				return err
+			}
+		}
+		for j := range parity {
+			parity[j] = make([]byte, n)
+		}
+		rs.encode(data, parity)
+		for j := range parity {
+			if _, err := w.WriteAt(parity[j], hlen+int64(j)*bs+off); err != nil {
+				return err
+			}
+		}
+	}
+	for j := 0; j < m; j++ {
+		if h.Sums[k+j], err = blockSum(w, hlen+int64(j)*bs, bs, hlen+int64(m)*bs); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.WriteAt(h.marshal(), 0); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0444); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// RepairParity reconstructs the pic's missing or damaged file from its parity
+// file.  The damaged file is only replaced once the reconstruction has been
+// verified, and the repair is recorded in the pic's meta data.  It returns
+// the number of damaged blocks that were recovered.
+func (p *Pic) RepairParity() (int, error) {
+	pf, err := os.Open(p.ParityPath())
+	if os.IsNotExist(err) {
+		return 0, ErrNoParity
+	} else if err != nil {
+		return 0, err
+	}
+	defer pf.Close()
+	h, err := readParityHeader(pf)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %v", p.ParityPath(), err)
+	} else if !bytes.Equal(h.Sum[:], p.Sum) {
+		return 0, fmt.Errorf("%v is for different content", p.ParityPath())
+	}
+	k, m, bs, hlen := int(h.K), int(h.M), int64(h.BlockSize), h.len()
+
+	// start from whatever is left of the damaged file
+	dst := p.Filepath()
+	tmp := dst + ".part"
+	w, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp)
+	defer w.Close()
+	if f, err := os.Open(dst); err == nil {
+		_, err = io.Copy(w, io.LimitReader(f, h.Size))
+		f.Close()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := w.Truncate(h.Size); err != nil {
+		return 0, err
+	}
+
+	// blocks whose sums don't match are erasures
+	var lost, good []int
+	for i := 0; i < k+m; i++ {
+		var sum [32]byte
+		if i < k {
+			sum, err = blockSum(w, int64(i)*bs, bs, h.Size)
+		} else {
+			sum, err = blockSum(pf, hlen+int64(i-k)*bs, bs, hlen+int64(m)*bs)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if sum != h.Sums[i] {
+			if i < k {
+				lost = append(lost, i)
+			}
+		} else if len(good) < k {
+			good = append(good, i)
+		}
+	}
+	if len(lost) == 0 {
+		if err := verifyFile(tmp, p.Sum); err != nil {
+			return 0, fmt.Errorf("file is damaged but all blocks match the parity file")
+		}
+	} else if len(good) < k {
+		return 0, fmt.Errorf("too much damage: %v of %v blocks are intact but %v are needed", len(good), k+m, k)
+	}
+
+	rs := newReedSolomon(k, m)
+	dec, err := rs.decoder(good, lost)
+	if err != nil {
+		return 0, err
+	}
+	shards := make([][]byte, k)
+	for off := int64(0); off < bs && len(lost) > 0; off += parityWindow {
+		n := min64(parityWindow, bs-off)
+		for s, i := range good {
+			shards[s] = make([]byte, n)
+			if i < k {
+				err = readPadded(w, shards[s], int64(i)*bs+off, h.Size)
+			} else {
+				err = readPadded(pf, shards[s], hlen+int64(i-k)*bs+off, hlen+int64(m)*bs)
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+		for r, i := range lost {
+			b := make([]byte, n)
+			for s := range shards {
+				gfMulAdd(b, shards[s], dec[r][s])
+			}
+			// drop the padding past the end of the file
+			start := int64(i)*bs + off
+			if start >= h.Size {
+				continue
+			}
+			b = b[:min64(n, h.Size-start)]
+			if _, err := w.WriteAt(b, start); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	if err := verifyFile(tmp, p.Sum); err != nil {
+		return 0, err
+	}
+	if err := os.Chmod(tmp, 0444); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return 0, err
+	}
+
+	if err := p.addMeta(RepairedField, ParityDir); err != nil {
+		return len(lost), err
+	}
+	return len(lost), p.Check()
+}
+
+// readPadded fills b with the content of r at off, zero padding anything at
+// or past end.
+func readPadded(r io.ReaderAt, b []byte, off, end int64) error {
+	for i := range b {
+		b[i] = 0
+	}
+	if off >= end {
+		return nil
+	}
+	n := min64(int64(len(b)), end-off)
+	_, err := r.ReadAt(b[:n], off)
+	if err == io.EOF { // a truncated file - treat the rest as zeros
+		err = nil
+	}
+	return err
+}
+
+// blockSum returns the sha256 of the zero padded block of r at off.
+func blockSum(r io.ReaderAt, off, size, end int64) (sum [32]byte, err error) {
+	h := sha256.New()
+	buf := make([]byte, parityWindow)
+	for n := int64(0); n < size; n += parityWindow {
+		b := buf[:min64(parityWindow, size-n)]
+		if err := readPadded(r, b, off+n, end); err != nil {
+			return sum, err
+		}
+		h.Write(b)
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// GF(2^8) arithmetic with the polynomial x^8+x^4+x^3+x^2+1.
+var (
+	gfExp [510]byte
+	gfLog [256]int
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfInv(a byte) byte { return gfExp[255-gfLog[a]] }
+
+// gfMulAdd adds c*src to dst.
+func gfMulAdd(dst, src []byte, c byte) {
+	if c == 0 {
+		return
+	}
+	var table [256]byte
+	for i := range table {
+		table[i] = gfMul(c, byte(i))
+	}
+	for i, v := range src {
+		dst[i] ^= table[v]
+	}
+}
+
+// reedSolomon is a systematic erasure code with k data and m parity shards.
+// Parity shard j is sum_i data_i / (x_i + y_j) with x_i = i and y_j = k+j -
+// a Cauchy matrix, every square submatrix of which is invertible, so any k
+// shards determine the data.
+type reedSolomon struct {
+	k, m   int
+	parity [][]byte // m x k coefficients
+}
+
+func newReedSolomon(k, m int) *reedSolomon {
+	rs := &reedSolomon{k: k, m: m, parity: make([][]byte, m)}
+	for j := range rs.parity {
+		rs.parity[j] = make([]byte, k)
+		for i := range rs.parity[j] {
+			rs.parity[j][i] = gfInv(byte(i) ^ byte(k+j))
+		}
+	}
+	return rs
+}
+
+// row returns the coefficients producing shard i from the data shards.
+func (rs *reedSolomon) row(i int) []byte {
+	if i >= rs.k {
+		return rs.parity[i-rs.k]
+	}
+	r := make([]byte, rs.k)
+	r[i] = 1
+	return r
+}
+
+func (rs *reedSolomon) encode(data, parity [][]byte) {
+	for j := range parity {
+		for i := range data {
+			gfMulAdd(parity[j], data[i], rs.parity[j][i])
+		}
+	}
+}
+
+// decoder returns, for each lost data shard, the coefficients that compute it
+// from the k shards in good.
+func (rs *reedSolomon) decoder(good, lost []int) ([][]byte, error) {
+	k := rs.k
+	a := make([][]byte, k)
+	inv := make([][]byte, k)
+	for r, i := range good {
+		a[r] = append([]byte{}, rs.row(i)...)
+		inv[r] = make([]byte, k)
+		inv[r][r] = 1
+	}
+
+	// Gauss-Jordan elimination
+	for c := 0; c < k; c++ {
+		p := c
+		for p < k && a[p][c] == 0 {
+			p++
+		}
+		if p == k {
+			return nil, errors.New("singular parity matrix")
+		}
+		a[c], a[p] = a[p], a[c]
+		inv[c], inv[p] = inv[p], inv[c]
+
+		s := gfInv(a[c][c])
+		for i := 0; i < k; i++ {
+			a[c][i] = gfMul(a[c][i], s)
+			inv[c][i] = gfMul(inv[c][i], s)
+		}
+		for r := 0; r < k; r++ {
+			if f := a[r][c]; r != c && f != 0 {
+				gfMulAdd(a[r], a[c], f)
+				gfMulAdd(inv[r], inv[c], f)
+			}
+		}
+	}
+
+	dec := make([][]byte, len(lost))
+	for r, i := range lost {
+		dec[r] = inv[i]
+	}
+	return dec, nil
+}
@@ -0,0 +1,257 @@
+package piclib
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// noisePic adds a jpeg of random noise (so it compresses badly and spans many
+// parity blocks) to l.
+func noisePic(t *testing.T, l *Lib, seed int64) *Pic {
+	rnd := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for i := range img.Pix {
+		img.Pix[i] = byte(rnd.Intn(256))
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(filepath.Dir(l.Path), "noise.jpg")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := l.AddFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func parityHeaderOf(t *testing.T, p *Pic) *parityHeader {
+	f, err := os.Open(p.ParityPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	h, err := readParityHeader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// corruptBlocks flips a byte in each of the given data blocks of p's file.
+func corruptBlocks(t *testing.T, p *Pic, h *parityHeader, blocks ...int) {
+	path := p.Filepath()
+	os.Chmod(path, 0644)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range blocks {
+		off := int64(i)*int64(h.BlockSize) + int64(h.BlockSize)/2
+		if off >= int64(len(data)) {
+			off = int64(len(data)) - 1
+		}
+		data[off] ^= 0xff
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRepairParity(t *testing.T) {
+	tests := []struct {
+		name       string
+		redundancy int
+		damage     func(t *testing.T, p *Pic, h *parityHeader) int // returns damaged blocks
+	}{
+		{"corrupt m blocks", 10, func(t *testing.T, p *Pic, h *parityHeader) int {
+			var blocks []int
+			for i := 0; i < int(h.M); i++ {
+				blocks = append(blocks, i*int(h.K)/int(h.M))
+			}
+			corruptBlocks(t, p, h, blocks...)
+			return len(blocks)
+		}},
+		{"corrupt last block", 10, func(t *testing.T, p *Pic, h *parityHeader) int {
+			corruptBlocks(t, p, h, int(h.K)-1)
+			return 1
+		}},
+		{"truncate", 10, func(t *testing.T, p *Pic, h *parityHeader) int {
+			lost := int(h.M)
+			size := int64(int(h.K)-lost) * int64(h.BlockSize)
+			os.Chmod(p.Filepath(), 0644)
+			if err := os.Truncate(p.Filepath(), size); err != nil {
+				t.Fatal(err)
+			}
+			return lost
+		}},
+		{"delete", 100, func(t *testing.T, p *Pic, h *parityHeader) int {
+			if err := os.Remove(p.Filepath()); err != nil {
+				t.Fatal(err)
+			}
+			return int(h.K)
+		}},
+	}
+
+	for _, test := range tests {
+		l, done := testLib(t)
+		p := noisePic(t, l, 1)
+		if err := p.WriteParity(test.redundancy); err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if !p.HasParity(test.redundancy) {
+			t.Errorf("%v: parity file not found after writing it", test.name)
+		}
+		h := parityHeaderOf(t, p)
+		if h.K < 2 {
+			t.Fatalf("%v: test file only spans %v block", test.name, h.K)
+		}
+
+		want := test.damage(t, p, h)
+		if err := p.Validate(); err == nil {
+			t.Errorf("%v: damaged file passed validation", test.name)
+		}
+		n, err := p.RepairParity()
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		} else if n != want {
+			t.Errorf("%v: repaired %v blocks, want %v", test.name, n, want)
+		} else if err := verifyFile(p.Filepath(), p.Sum); err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		}
+		done()
+	}
+}
+
+func TestRepairParityTooMuchDamage(t *testing.T) {
+	l, done := testLib(t)
+	defer done()
+	p := noisePic(t, l, 2)
+	if err := p.WriteParity(10); err != nil {
+		t.Fatal(err)
+	}
+	h := parityHeaderOf(t, p)
+
+	var blocks []int
+	for i := 0; i <= int(h.M); i++ {
+		blocks = append(blocks, i)
+	}
+	corruptBlocks(t, p, h, blocks...)
+	if _, err := p.RepairParity(); err == nil || !strings.Contains(err.Error(), "too much damage") {
+		t.Errorf("got error %v, want too much damage", err)
+	}
+	if err := p.Validate(); err == nil {
+		t.Error("unrepairable file was replaced with one passing validation")
+	}
+}
+
+func TestParityHeaderDamaged(t *testing.T) {
+	l, done := testLib(t)
+	defer done()
+	p := noisePic(t, l, 3)
+	if err := p.WriteParity(10); err != nil {
+		t.Fatal(err)
+	}
+
+	h := parityHeaderOf(t, p)
+	path := p.ParityPath()
+	os.Chmod(path, 0644)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(parityMagic)+32+7] ^= 1 // the low byte of the file size
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.HasParity(10) {
+		t.Error("damaged parity header accepted by HasParity")
+	}
+	corruptBlocks(t, p, h, 0)
+	if _, err := p.RepairParity(); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("got error %v, want a header checksum failure", err)
+	}
+}
+
+func TestReedSolomon(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	for iter := 0; iter < 200; iter++ {
+		k, m := 1+rnd.Intn(40), 1+rnd.Intn(20)
+		n := 1 + rnd.Intn(64)
+		rs := newReedSolomon(k, m)
+
+		data := make([][]byte, k)
+		for i := range data {
+			data[i] = make([]byte, n)
+			rnd.Read(data[i])
+		}
+		parity := make([][]byte, m)
+		for j := range parity {
+			parity[j] = make([]byte, n)
+		}
+		rs.encode(data, parity)
+
+		// erase up to m random data shards and decode from k random survivors
+		perm := rnd.Perm(k + m)
+		nlost := rnd.Intn(m + 1)
+		var lost []int
+		for _, i := range perm {
+			if i < k && len(lost) < nlost {
+				lost = append(lost, i)
+			}
+		}
+		isLost := map[int]bool{}
+		for _, i := range lost {
+			isLost[i] = true
+		}
+		var good [][]byte
+		var goodIdx []int
+		for _, i := range perm {
+			if isLost[i] || len(goodIdx) == k {
+				continue
+			}
+			goodIdx = append(goodIdx, i)
+			if i < k {
+				good = append(good, data[i])
+			} else {
+				good = append(good, parity[i-k])
+			}
+		}
+
+		dec, err := rs.decoder(goodIdx, lost)
+		if err != nil {
+			t.Fatalf("k=%v m=%v lost=%v: %v", k, m, lost, err)
+		}
+		for r, i := range lost {
+			b := make([]byte, n)
+			for s := range good {
+				gfMulAdd(b, good[s], dec[r][s])
+			}
+			if !bytes.Equal(b, data[i]) {
+				t.Fatalf("k=%v m=%v good=%v: shard %v decoded incorrectly", k, m, goodIdx, i)
+			}
+		}
+	}
+}
+
+func TestGF(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if got := gfMul(byte(a), gfInv(byte(a))); got != 1 {
+			t.Fatalf("%v * inv(%v) = %v", a, a, got)
+		}
+	}
+	if gfMul(0, 7) != 0 || gfMul(7, 0) != 0 {
+		t.Error("multiplication by zero isn't zero")
+	}
+}
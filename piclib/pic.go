@@ -4,11 +4,17 @@ import (
 	"bytes"
 	"database/sql"
 	"fmt"
+	"image/jpeg"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/nfnt/resize"
 )
 
 type Pic struct {
@@ -20,9 +26,46 @@ type Pic struct {
 	Added  time.Time
 	Taken  time.Time
 	Orient int // EXIF orientation (1 through 8)
+	// TakenEnd and Precision describe approximate taken dates (see Date).
+	TakenEnd  time.Time
+	Precision Precision
+	Rating    int // 0 (unrated) through 5 stars
+	Flag      Flag
+	Label     string    // color label (see Labels)
+	Deleted   time.Time // when the pic was moved to the trash (zero if not)
+	Hidden    bool      // private pics excluded from default listings
+	Checked   time.Time // when the pic's file was last verified (zero if never)
+	CheckErr  string    // the last verification's error (empty if it passed)
+	Stack     int       // id of the top pic of the pic's stack (0 if unstacked)
+	Parent    int       // id of the pic this is an edited version of (0 if none)
+}
+
+// Flag marks a pic as a pick or a reject while culling.
+type Flag int
+
+const (
+	Rejected  Flag = -1
+	Unflagged Flag = 0
+	Picked    Flag = 1
+)
+
+func (f Flag) String() string {
+	switch f {
+	case Rejected:
+		return "reject"
+	case Picked:
+		return "pick"
+	}
+	return ""
 }
 
+// Labels are the valid color labels.
+var Labels = []string{"red", "yellow", "green", "blue", "purple"}
+
 func (p *Pic) Filepath() string {
+	if !p.Deleted.IsZero() {
+		return filepath.Join(p.lib.Path, TrashDir, diskname(p.Name, p.Sum))
+	}
 	return filepath.Join(p.lib.Path, diskname(p.Name, p.Sum))
 }
 
@@ -33,9 +76,9 @@ func (p *Pic) Open() (io.ReadCloser, error) {
 }
 
 func (p *Pic) GetMeta(field string) (string, error) {
-	s := "SELECT value FROM meta WHERE id=? ORDER BY time DESC LIMIT 1;"
+	s := "SELECT value FROM meta WHERE id=? AND field=? ORDER BY time DESC LIMIT 1;"
 	val := ""
-	err := p.lib.db.QueryRow(s, p.id).Scan(&val)
+	err := p.lib.db.QueryRow(s, p.id, field).Scan(&val)
 	if err != nil && err != sql.ErrNoRows {
 		return "", err
 	}
@@ -49,23 +92,165 @@ func (p *Pic) SetMeta(field, val string) error {
 	} else if curr == val { // value is already the same
 		return nil
 	}
+	return p.addMeta(field, val)
+}
 
+// addMeta records val in field's history even if it is unchanged.
+func (p *Pic) addMeta(field, val string) error {
 	s := "INSERT INTO meta (id,time,field,value) VALUES (?,?,?,?);"
-	_, err = p.lib.db.Exec(s, p.id, time.Now(), field, val)
+	_, err := p.lib.db.Exec(s, p.id, time.Now(), field, val)
 	return err
 }
 
 func (p *Pic) SetNotes(val string) error { return p.SetMeta(NotesField, val) }
 func (p *Pic) GetNotes() (string, error) { return p.GetMeta(NotesField) }
 
-func (p *Pic) Validate() error {
+// Tags returns the pic's keywords.
+func (p *Pic) Tags() ([]string, error) {
+	val, err := p.GetMeta(TagsField)
+	if err != nil || val == "" {
+		return nil, err
+	}
+	return strings.Split(val, "\n"), nil
+}
+
+// SetTags replaces the pic's keywords.
+func (p *Pic) SetTags(tags []string) error {
+	var clean []string
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t != "" && !seen[t] {
+			clean = append(clean, t)
+			seen[t] = true
+		}
+	}
+	sort.Strings(clean)
+	return p.SetMeta(TagsField, strings.Join(clean, "\n"))
+}
+
+// SetRating sets the pic's star rating (0 through 5).
+func (p *Pic) SetRating(r int) error {
+	if r < 0 || r > 5 {
+		return fmt.Errorf("invalid rating %v (must be 0 through 5)", r)
+	}
+	_, err := p.lib.db.Exec("UPDATE files SET rating=? WHERE id=?;", r, p.id)
+	if err != nil {
+		return err
+	}
+	p.Rating = r
+	return nil
+}
+
+// SetFlag marks the pic as picked, rejected or unflagged.
+func (p *Pic) SetFlag(f Flag) error {
+	if f < Rejected || f > Picked {
+		return fmt.Errorf("invalid flag %v", int(f))
+	}
+	_, err := p.lib.db.Exec("UPDATE files SET flag=? WHERE id=?;", f, p.id)
+	if err != nil {
+		return err
+	}
+	p.Flag = f
+	return nil
+}
+
+// SetHidden marks the pic as private (or not).  Hidden pics are excluded from
+// listings unless the library's IncludeHidden is set.
+func (p *Pic) SetHidden(hidden bool) error {
+	_, err := p.lib.db.Exec("UPDATE files SET hidden=? WHERE id=?;", hidden, p.id)
+	if err != nil {
+		return err
+	}
+	p.Hidden = hidden
+	return nil
+}
+
+// SetLabel sets the pic's color label.  An empty label clears it.
+func (p *Pic) SetLabel(label string) error {
+	label = strings.ToLower(label)
+	valid := label == ""
+	for _, l := range Labels {
+		valid = valid || l == label
+	}
+	if !valid {
+		return fmt.Errorf("invalid label '%v' (must be one of %v)", label, strings.Join(Labels, ", "))
+	}
+
+	_, err := p.lib.db.Exec("UPDATE files SET label=? WHERE id=?;", label, p.id)
+	if err != nil {
+		return err
+	}
+	p.Label = label
+	return nil
+}
+
+// SetLocation records the GPS coordinates (decimal degrees) where the pic was
+// taken.
+func (p *Pic) SetLocation(lat, lon float64) error {
+	return p.SetMeta(GPSField, fmt.Sprintf("%.7f,%.7f", lat, lon))
+}
+
+// Location returns the pic's GPS coordinates.  ok is false if no location has
+// been recorded.
+func (p *Pic) Location() (lat, lon float64, ok bool, err error) {
+	val, err := p.GetMeta(GPSField)
+	if err != nil || val == "" {
+		return 0, 0, false, err
+	}
+	_, err = fmt.Sscanf(val, "%f,%f", &lat, &lon)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("pic %v has malformed location '%v'", p.id, val)
+	}
+	return lat, lon, true, nil
+}
+
+// When returns the possibly approximate date the pic was taken.
+func (p *Pic) When() Date { return Date{Start: p.Taken, End: p.TakenEnd, Precision: p.Precision} }
+
+// SetTaken changes the time the pic was taken to an exact time.
+func (p *Pic) SetTaken(t time.Time) error { return p.SetDate(ExactDate(t)) }
+
+// SetDate changes the date the pic was taken.  The original date and every
+// subsequent change are recorded in the pic's meta history.
+func (p *Pic) SetDate(d Date) error {
+	hist, err := p.GetMeta(TakenField)
+	if err != nil {
+		return err
+	} else if hist == "" {
+		if err := p.SetMeta(TakenField, p.When().String()); err != nil {
+			return err
+		}
+	}
+
+	s := "UPDATE files SET taken=?,takenend=?,precision=? WHERE id=?;"
+	_, err = p.lib.db.Exec(s, d.Start.Unix(), d.End.Unix(), d.Precision, p.id)
+	if err != nil {
+		return err
+	}
+	p.Taken, p.TakenEnd, p.Precision = d.Start, d.End, d.Precision
+	return p.SetMeta(TakenField, d.String())
+}
+
+// ShiftTaken moves the date the pic was taken by d.
+func (p *Pic) ShiftTaken(d time.Duration) error {
+	return p.SetDate(Date{Start: p.Taken.Add(d), End: p.TakenEnd.Add(d), Precision: p.Precision})
+}
+
+func (p *Pic) Validate() error { return p.validate(nil) }
+
+func (p *Pic) validate(t *throttle) error {
 	rc, err := p.Open()
 	if err != nil {
 		return err
 	}
 	defer rc.Close()
 
-	sum, err := Sha256(rc)
+	var r io.Reader = rc
+	if t != nil {
+		r = t.reader(rc)
+	}
+	sum, err := Sha256(r)
 	if err != nil {
 		return err
 	}
@@ -76,14 +261,116 @@ func (p *Pic) Validate() error {
 	return nil
 }
 
+// Thumb returns the pic's thumbnail, rebuilding it if it is missing from the
+// cache.
 func (p *Pic) Thumb() ([]byte, error) {
-	s := "SELECT thumb FROM files WHERE id=?"
-	data := []byte{}
-	err := p.lib.db.QueryRow(s, p.id).Scan(&data)
+	s := "SELECT thumbkey, thumb FROM files WHERE id=?"
+	key, data := "", []byte{}
+	err := p.lib.db.QueryRow(s, p.id).Scan(&key, &data)
 	if err != nil {
 		return nil, err
+	} else if key == "" { // not migrated yet or no thumbnail
+		return data, nil
+	}
+
+	data, err = ioutil.ReadFile(p.lib.cachePath(key))
+	if os.IsNotExist(err) {
+		if err := p.RebuildThumb(); err != nil {
+			return nil, err
+		}
+		return p.Thumb()
+	}
+	return data, err
+}
+
+// cwOrient maps each EXIF orientation to the orientation that displays the
+// image rotated a further 90 degrees clockwise.
+var cwOrient = map[int]int{1: 6, 6: 3, 3: 8, 8: 1, 2: 7, 7: 4, 4: 5, 5: 2}
+
+// SetOrient overrides the pic's EXIF orientation (1 through 8) and regenerates
+// its thumbnail.  The original orientation and every subsequent change are
+// recorded in the pic's meta history.
+func (p *Pic) SetOrient(orient int) error {
+	if _, ok := cwOrient[orient]; !ok {
+		return fmt.Errorf("invalid orientation %v (must be 1 through 8)", orient)
+	}
+
+	hist, err := p.GetMeta(OrientField)
+	if err != nil {
+		return err
+	} else if hist == "" {
+		if err := p.SetMeta(OrientField, strconv.Itoa(p.Orient)); err != nil {
+			return err
+		}
+	}
+
+	_, err = p.lib.db.Exec("UPDATE files SET orient=? WHERE id=?;", orient, p.id)
+	if err != nil {
+		return err
+	}
+	p.Orient = orient
+	if err := p.SetMeta(OrientField, strconv.Itoa(orient)); err != nil {
+		return err
+	}
+	return p.RebuildThumb()
+}
+
+// Rotate turns the pic 90 degrees clockwise (or counter-clockwise) by
+// overriding its orientation.
+func (p *Pic) Rotate(clockwise bool) error {
+	orient := p.Orient
+	if _, ok := cwOrient[orient]; !ok {
+		orient = 1
+	}
+
+	n := 1
+	if !clockwise {
+		n = 3
+	}
+	for i := 0; i < n; i++ {
+		orient = cwOrient[orient]
+	}
+	return p.SetOrient(orient)
+}
+
+// Modified returns true if the pic's edit stack or orientation override mean
+// that its original file no longer displays the same as the pic.
+func (p *Pic) Modified() (bool, error) {
+	edits, err := p.Edits()
+	if err != nil || len(edits) > 0 {
+		return len(edits) > 0, err
+	}
+
+	s := "SELECT value FROM meta WHERE id=? AND field=? ORDER BY time ASC LIMIT 1;"
+	orig := ""
+	err = p.lib.db.QueryRow(s, p.id, OrientField).Scan(&orig)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if orig == "0" { // no EXIF orientation displays the same as 1
+		orig = "1"
+	}
+	return orig != strconv.Itoa(p.Orient), nil
+}
+
+// RebuildThumb regenerates the pic's thumbnail from its original file,
+// orientation and edit stack.
+func (p *Pic) RebuildThumb() error {
+	img, release, err := p.image()
+	if err != nil {
+		return err
+	}
+	w, h := p.lib.thumbSize()
+	m := resize.Resize(uint(w), uint(h), img, resize.Bicubic)
+	release()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, m, nil); err != nil {
+		return err
 	}
-	return data, nil
+	return p.lib.setThumb(p.id, buf.Bytes())
 }
 
 type BadSumErr Pic
@@ -0,0 +1,279 @@
+package piclib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// rawExts are the extensions of raw formats that previews can be extracted
+// from - TIFF based formats and RAF.
+var rawExts = map[string]bool{
+	".3fr": true, ".arw": true, ".cr2": true, ".dng": true, ".erf": true,
+	".iiq": true, ".kdc": true, ".mrw": true, ".nef": true, ".nrw": true,
+	".orf": true, ".pef": true, ".raf": true, ".rw2": true, ".rwl": true,
+	".sr2": true, ".srf": true, ".srw": true,
+}
+
+// otherRawExts are raw formats using other containers (Canon CR3 and CRW,
+// Sigma X3F), which are stored without previews.
+var otherRawExts = []string{".cr3", ".crw", ".x3f"}
+
+// IsRaw returns true if name has a camera raw file extension.
+func IsRaw(name string) bool {
+	f, ok := LookupFormat(name)
+	return ok && f.Name == "raw"
+}
+
+// ErrNoPreview is returned for raw files without a usable embedded preview.
+var ErrNoPreview = errors.New("no embedded jpeg preview found")
+
+// TIFF tags locating embedded previews.
+const (
+	tagCompression  = 0x103
+	tagStripOffsets = 0x111
+	tagStripCounts  = 0x117
+	tagSubIFDs      = 0x14a
+	tagJPEGOffset   = 0x201
+	tagJPEGLength   = 0x202
+	tagJpgFromRaw   = 0x2e // Panasonic
+)
+
+const (
+	maxIFDs    = 64
+	maxPreview = 64 << 20
+)
+
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	val   [4]byte // the value, or its offset if it doesn't fit
+}
+
+type ifd map[uint16]ifdEntry
+
+// readIFD reads the TIFF image file directory at off, returning it and the
+// offset of the next one.
+func readIFD(r io.ReaderAt, order binary.ByteOrder, off int64) (ifd, int64, error) {
+	var n [2]byte
+	if _, err := r.ReadAt(n[:], off); err != nil {
+		return nil, 0, err
+	}
+	count := int(order.Uint16(n[:]))
+	buf := make([]byte, count*12+4)
+	if _, err := r.ReadAt(buf, off+2); err != nil {
+		return nil, 0, err
+	}
+
+	d := ifd{}
+	for i := 0; i < count; i++ {
+		b := buf[i*12:]
+		var e ifdEntry
+		e.typ = order.Uint16(b[2:])
+		e.count = order.Uint32(b[4:])
+		copy(e.val[:], b[8:12])
+		d[order.Uint16(b)] = e
+	}
+	return d, int64(order.Uint32(buf[count*12:])), nil
+}
+
+// ints returns the integer values of a SHORT, LONG or IFD tag.
+func (d ifd) ints(r io.ReaderAt, order binary.ByteOrder, tag uint16) []int64 {
+	e, ok := d[tag]
+	if !ok || e.count > 1024 {
+		return nil
+	}
+	size := 4
+	if e.typ == 3 { // SHORT
+		size = 2
+	} else if e.typ != 4 && e.typ != 13 { // LONG, IFD
+		return nil
+	}
+
+	data := e.val[:]
+	if n := size * int(e.count); n > 4 {
+		data = make([]byte, n)
+		if _, err := r.ReadAt(data, int64(order.Uint32(e.val[:]))); err != nil {
+			return nil
+		}
+	}
+	vals := make([]int64, e.count)
+	for i := range vals {
+		if size == 2 {
+			vals[i] = int64(order.Uint16(data[i*2:]))
+		} else {
+			vals[i] = int64(order.Uint32(data[i*4:]))
+		}
+	}
+	return vals
+}
+
+func (d ifd) int(r io.ReaderAt, order binary.ByteOrder, tag uint16) (int64, bool) {
+	vals := d.ints(r, order, tag)
+	if len(vals) != 1 {
+		return 0, false
+	}
+	return vals[0], true
+}
+
+// previewSpan is the location of an embedded jpeg.
+type previewSpan struct{ off, n int64 }
+
+// previewSpans returns the locations of jpegs that the IFDs of a TIFF based
+// raw file say are embedded in it.
+func previewSpans(r io.ReaderAt) ([]previewSpan, error) {
+	var hdr [8]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder
+	switch string(hdr[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("not a TIFF based raw file")
+	}
+	// Olympus and Panasonic use their own magic numbers in place of 42
+	switch order.Uint16(hdr[2:]) {
+	case 42, 0x4f52, 0x5352, 0x55:
+	default:
+		return nil, errors.New("not a TIFF based raw file")
+	}
+
+	var spans []previewSpan
+	queue := []int64{int64(order.Uint32(hdr[4:]))}
+	seen := map[int64]bool{}
+	for len(queue) > 0 && len(seen) < maxIFDs {
+		off := queue[0]
+		queue = queue[1:]
+		if off == 0 || seen[off] {
+			continue
+		}
+		seen[off] = true
+		d, next, err := readIFD(r, order, off)
+		if err != nil {
+			continue
+		}
+		queue = append(queue, next)
+		queue = append(queue, d.ints(r, order, tagSubIFDs)...)
+
+		if off, ok := d.int(r, order, tagJPEGOffset); ok {
+			if n, ok := d.int(r, order, tagJPEGLength); ok {
+				spans = append(spans, previewSpan{off, n})
+			}
+		}
+		if c, _ := d.int(r, order, tagCompression); c == 6 || c == 7 {
+			off, ok1 := d.int(r, order, tagStripOffsets)
+			n, ok2 := d.int(r, order, tagStripCounts)
+			if ok1 && ok2 {
+				spans = append(spans, previewSpan{off, n})
+			}
+		}
+		if e, ok := d[tagJpgFromRaw]; ok && e.count > 4 {
+			spans = append(spans, previewSpan{int64(order.Uint32(e.val[:])), int64(e.count)})
+		}
+	}
+	return spans, nil
+}
+
+// rafSpans returns the location of the jpeg in a Fujifilm raw file.
+func rafSpans(r io.ReaderAt) ([]previewSpan, error) {
+	var hdr [92]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, err
+	} else if !bytes.HasPrefix(hdr[:], []byte("FUJIFILMCCD-RAW")) {
+		return nil, errors.New("not a Fujifilm raw file")
+	}
+	off := binary.BigEndian.Uint32(hdr[84:])
+	n := binary.BigEndian.Uint32(hdr[88:])
+	return []previewSpan{{int64(off), int64(n)}}, nil
+}
+
+// RawPreview returns the largest jpeg preview embedded in a camera raw file.
+// TIFF based formats (most of them, e.g. CR2, NEF, ARW, DNG, ORF, RW2 and
+// PEF) and Fujifilm RAF files are supported.
+func RawPreview(r io.ReaderAt) ([]byte, error) {
+	spans, err := rafSpans(r)
+	if err != nil {
+		if spans, err = previewSpans(r); err != nil {
+			return nil, err
+		}
+	}
+
+	var best []byte
+	bestArea := 0
+	for _, s := range spans {
+		if s.n < 4 || s.n > maxPreview {
+			continue
+		}
+		data := make([]byte, s.n)
+		if _, err := r.ReadAt(data, s.off); err != nil {
+			continue
+		}
+		// raw image data is often lossless jpeg, which this rejects
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+		if area := cfg.Width * cfg.Height; area > bestArea {
+			best, bestArea = data, area
+		}
+	}
+	if best == nil {
+		return nil, ErrNoPreview
+	}
+	return best, nil
+}
+
+// rawPreview returns the embedded preview of the raw file read by r.
+func rawPreview(r io.Reader) ([]byte, error) {
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		ra = bytes.NewReader(data)
+	}
+	return RawPreview(ra)
+}
+
+// decodeRaw decodes the embedded preview of a raw file.
+func decodeRaw(r io.Reader) (image.Image, error) {
+	data, err := rawPreview(r)
+	if err != nil {
+		return nil, err
+	}
+	return jpeg.Decode(bytes.NewReader(data))
+}
+
+func decodeRawConfig(r io.Reader) (image.Config, error) {
+	data, err := rawPreview(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return jpeg.DecodeConfig(bytes.NewReader(data))
+}
+
+// decodeExif returns the EXIF data in f, falling back to that of the
+// embedded preview of raw files the EXIF decoder can't parse.
+func decodeExif(name string, f *os.File) (*exif.Exif, error) {
+	x, err := exif.Decode(f)
+	if err == nil || !IsRaw(name) {
+		return x, err
+	}
+	data, perr := RawPreview(f)
+	if perr != nil {
+		return nil, err
+	}
+	return exif.Decode(bytes.NewReader(data))
+}
@@ -0,0 +1,106 @@
+package piclib
+
+import (
+	"bytes"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// The fixtures in testdata hold 16x12 and 48x32 jpeg previews: preview.dng is
+// a TIFF with the small one as its IFD0 thumbnail, the large one in a SubIFD
+// and a DateTimeOriginal in its EXIF IFD; preview.raf is a Fujifilm header
+// pointing at the large one, which has the date in its own EXIF data.
+
+func TestRawPreview(t *testing.T) {
+	for _, name := range []string{"preview.dng", "preview.raf"} {
+		f, err := os.Open(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := RawPreview(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%v: preview is not a jpeg: %v", name, err)
+		} else if cfg.Width != 48 || cfg.Height != 32 {
+			t.Errorf("%v: got %vx%v preview, want the largest (48x32)", name, cfg.Width, cfg.Height)
+		}
+
+		f, err = os.Open(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := decodeImage(name, f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+		} else if b := img.Bounds(); b.Dx() != 48 || b.Dy() != 32 {
+			t.Errorf("%v: decoded %v, want 48x32", name, b)
+		}
+	}
+}
+
+func TestRawPreviewInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		[]byte("not a raw file at all"),
+		[]byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00"), // no entries
+		[]byte("II*\x00\xff\xff\xff\x7f"),                         // IFD past the end
+	} {
+		if _, err := RawPreview(bytes.NewReader(data)); err == nil {
+			t.Errorf("%q: got a preview", data)
+		}
+	}
+}
+
+func TestDecodeExif(t *testing.T) {
+	want := time.Date(2019, 7, 4, 18, 30, 15, 0, time.Local)
+	for _, name := range []string{"preview.dng", "preview.raf"} {
+		f, err := os.Open(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, err := decodeExif(name, f)
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			f.Close()
+			continue
+		}
+		if tag, err := x.Get(exif.DateTimeOriginal); err != nil {
+			t.Errorf("%v: %v", name, err)
+		} else if s, _ := tag.StringVal(); s != "2019:07:04 18:30:15" {
+			t.Errorf("%v: got DateTimeOriginal %q", name, s)
+		}
+
+		f.Seek(0, os.SEEK_SET)
+		got, err := exifDate(name, f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+		} else if !got.Equal(want) {
+			t.Errorf("%v: got taken %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestIsRaw(t *testing.T) {
+	for name, want := range map[string]bool{
+		"IMG_0001.CR2": true,
+		"IMG_0001.cr3": true,
+		"DSCF0001.RAF": true,
+		"IMG_0001.JPG": false,
+		"clip.mov":     false,
+	} {
+		if got := IsRaw(name); got != want {
+			t.Errorf("IsRaw(%v) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package piclib
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RepairedField records where a damaged pic file was restored from.
+const RepairedField = "Repaired"
+
+// Replica is a location that may hold copies of library files, such as a
+// backup directory, another library or an HTTP mirror of either.
+type Replica interface {
+	// Each calls fn with the content of each copy of the library file with
+	// the given name held by the replica until fn returns nil.  It returns
+	// the last error if no copy was accepted.
+	Each(name string, fn func(r io.Reader) error) error
+	String() string
+}
+
+// ParseReplica returns the replica at loc - either an http(s) URL or a
+// directory path.
+func ParseReplica(loc string) (Replica, error) {
+	if strings.HasPrefix(loc, "http://") || strings.HasPrefix(loc, "https://") {
+		return &httpReplica{url: strings.TrimRight(loc, "/"), client: &http.Client{Timeout: replicaTimeout}}, nil
+	}
+	info, err := os.Stat(loc)
+	if err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("replica %v is not a directory", loc)
+	}
+	return dirReplica(loc), nil
+}
+
+// replicaPaths are where a file may be found relative to a replica's root:
+// a library, a library's trash and a backup.
+var replicaPaths = []string{"", TrashDir, backupFiles}
+
+// replicaTimeout limits how long fetching a file from an http replica may
+// take.
+const replicaTimeout = 10 * time.Minute
+
+type dirReplica string
+
+func (d dirReplica) String() string { return string(d) }
+
+func (d dirReplica) Each(name string, fn func(r io.Reader) error) error {
+	err := fmt.Errorf("%v not found in %v", name, d)
+	for _, sub := range replicaPaths {
+		f, err1 := os.Open(filepath.Join(string(d), sub, name))
+		if err1 != nil {
+			continue
+		}
+		err = fn(f)
+		f.Close()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+type httpReplica struct {
+	url    string
+	client *http.Client
+}
+
+func (h *httpReplica) String() string { return h.url }
+
+func (h *httpReplica) Each(name string, fn func(r io.Reader) error) error {
+	err := fmt.Errorf("%v not found at %v", name, h.url)
+	for _, sub := range replicaPaths {
+		url := h.url + "/" + name
+		if sub != "" {
+			url = h.url + "/" + sub + "/" + name
+		}
+		resp, err1 := h.client.Get(url)
+		if err1 != nil {
+			err = err1
+			continue
+		} else if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			continue
+		}
+		err = fn(resp.Body)
+		resp.Body.Close()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// Repair replaces the pic's missing or damaged file with the first copy found
+// in replicas that matches the pic's sum.  The replacement is verified before
+// atomically taking the damaged file's place, and the repair is recorded in
+// the pic's meta data.  It returns the replica the file was restored from.
+func (p *Pic) Repair(replicas []Replica) (Replica, error) {
+	name := diskname(p.Name, p.Sum)
+	for _, rep := range replicas {
+		err := rep.Each(name, func(r io.Reader) error {
+			return writeVerified(r, p.Filepath(), p.Sum)
+		})
+		if err != nil {
+			continue
+		}
+
+		if err := p.addMeta(RepairedField, rep.String()); err != nil {
+			return rep, err
+		}
+		return rep, p.Check()
+	}
+	return nil, fmt.Errorf("no replica has a valid copy of %v", name)
+}
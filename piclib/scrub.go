@@ -0,0 +1,138 @@
+package piclib
+
+import (
+	"io"
+	"time"
+)
+
+// throttle limits the combined read rate of the readers it wraps.
+type throttle struct {
+	rate  int64 // bytes per second
+	start time.Time
+	n     int64
+}
+
+func newThrottle(rate int64) *throttle {
+	if rate <= 0 {
+		return nil
+	}
+	return &throttle{rate: rate, start: time.Now()}
+}
+
+func (t *throttle) reader(r io.Reader) io.Reader { return &throttledReader{t, r} }
+
+type throttledReader struct {
+	t *throttle
+	r io.Reader
+}
+
+func (tr *throttledReader) Read(b []byte) (int, error) {
+	t := tr.t
+	if max := int(t.rate); len(b) > max { // don't burst far past the rate
+		b = b[:max]
+	}
+	n, err := tr.r.Read(b)
+	t.n += int64(n)
+	due := t.start.Add(time.Duration(float64(t.n) / float64(t.rate) * float64(time.Second)))
+	time.Sleep(due.Sub(time.Now()))
+	return n, err
+}
+
+// Check verifies the pic's file like Validate and records the time and result
+// in the library.
+func (p *Pic) Check() error {
+	verr, err := p.check(nil)
+	if err != nil {
+		return err
+	}
+	return verr
+}
+
+// check returns the verification error and any error recording it.
+func (p *Pic) check(t *throttle) (verr, err error) {
+	verr = p.validate(t)
+	msg := ""
+	if verr != nil {
+		msg = verr.Error()
+	}
+
+	now := time.Now()
+	_, err = p.lib.db.Exec("UPDATE files SET checked=?,checkerr=? WHERE id=?;", now.Unix(), msg, p.id)
+	if err != nil {
+		return verr, err
+	}
+	p.Checked, p.CheckErr = now, msg
+	return verr, nil
+}
+
+// ScrubOptions control a Scrub run.
+type ScrubOptions struct {
+	// MaxAge skips pics that were verified more recently than this.
+	MaxAge time.Duration
+	// Limit is the maximum number of pics to verify (0 for no limit).
+	Limit int
+	// Rate limits reading to this many bytes per second (0 for no limit).
+	Rate int64
+}
+
+// Scrub verifies the library's files - least recently verified first -
+// recording each result.  Because progress is recorded as it goes, an
+// interrupted scrub resumes where it left off.  If fn is not nil, it is
+// called with each pic and its verification error.  Scrub returns the number
+// of pics verified.
+func (l *Lib) Scrub(opts ScrubOptions, fn func(p *Pic, err error)) (n int, err error) {
+	t := newThrottle(opts.Rate)
+	// pics verified during this run are never before the cutoff
+	cutoff := time.Now().Add(-opts.MaxAge).Unix()
+	const batch = 100
+	for {
+		s := "SELECT " + piccols + " FROM files WHERE checked<? ORDER BY checked,id LIMIT ?;"
+		pics, err := l.queryPics(s, cutoff, batch)
+		if err != nil {
+			return n, err
+		} else if len(pics) == 0 {
+			return n, nil
+		}
+
+		for _, p := range pics {
+			if opts.Limit > 0 && n >= opts.Limit {
+				return n, nil
+			}
+			verr, err := p.check(t)
+			if err != nil {
+				return n, err
+			}
+			n++
+			if fn != nil {
+				fn(p, verr)
+			}
+		}
+	}
+}
+
+// ScrubStatus summarizes the recorded verification results for a library.
+type ScrubStatus struct {
+	Total  int
+	Never  int       // pics that have never been verified
+	Due    int       // pics not verified within the age given to ScrubStatus
+	Oldest time.Time // least recent verification among verified pics
+	Failed []*Pic    // pics whose last verification failed
+}
+
+// ScrubStatus reports verification results, counting pics not verified
+// within age as due.
+func (l *Lib) ScrubStatus(age time.Duration) (*ScrubStatus, error) {
+	st := &ScrubStatus{}
+	var oldest int64
+	s := "SELECT COUNT(*), COALESCE(SUM(checked=0),0), COALESCE(SUM(checked<?),0), COALESCE(MIN(NULLIF(checked,0)),0) FROM files;"
+	err := l.db.QueryRow(s, time.Now().Add(-age).Unix()).Scan(&st.Total, &st.Never, &st.Due, &oldest)
+	if err != nil {
+		return nil, err
+	}
+	if oldest != 0 {
+		st.Oldest = time.Unix(oldest, 0)
+	}
+
+	st.Failed, err = l.queryPics("SELECT " + piccols + " FROM files WHERE checkerr!='' ORDER BY checked DESC;")
+	return st, err
+}
@@ -0,0 +1,246 @@
+package piclib
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Stacks group pics that are versions of the same shot - a RAW+JPEG pair,
+// the frames of a burst or the still and video of a live photo.  Each pic
+// in a stack records the id of the stack's top pic, which represents the
+// stack in collapsed listings.
+
+// stemWindow is how far apart the taken times of files with the same name
+// stem may be for them to be stacked.  Live photo videos start a little
+// before their still.
+const stemWindow = 5 * time.Second
+
+// burstWindow limits the search for other frames of a burst.
+const burstWindow = 10 * time.Minute
+
+// burstName matches the names phones give burst frames, e.g.
+// "IMG_20200101_120000_BURST001_COVER.jpg" or
+// "00001IMG_00001_BURST20200101120000123.jpg".
+var burstName = regexp.MustCompile(`(?i)^(.*?)_?BURST(\d+)`)
+
+// BurstID returns the burst that the file called name belongs to according
+// to its name (empty if none).
+func BurstID(name string) string {
+	m := burstName.FindStringSubmatch(filepath.Base(name))
+	if m == nil {
+		return ""
+	} else if len(m[2]) >= 14 { // a timestamp shared by the burst's frames
+		return m[2]
+	}
+	return strings.ToUpper(m[1])
+}
+
+func stem(name string) string {
+	return strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+}
+
+// StackMembers returns the pics in the same stack as p (including p), top
+// first.  Pics that aren't stacked are returned alone.
+func (p *Pic) StackMembers() ([]*Pic, error) {
+	top, err := p.stackTop()
+	if err != nil {
+		return nil, err
+	} else if top == 0 {
+		return []*Pic{p}, nil
+	}
+	s := "SELECT " + piccols + " FROM files WHERE stack=? ORDER BY id=? DESC, taken, id;"
+	return p.lib.queryPics(s, top, top)
+}
+
+// stackTop returns the id of p's stack top as currently recorded.
+func (p *Pic) stackTop() (int, error) {
+	err := p.lib.db.QueryRow("SELECT stack FROM files WHERE id=?;", p.id).Scan(&p.Stack)
+	return p.Stack, err
+}
+
+// topScore ranks how well a pic represents a stack.
+func topScore(p *Pic) int {
+	score := 0
+	if f, ok := LookupFormat(p.Name); ok && f.Decode != nil && f.Name != "raw" {
+		score += 4 // a rendered image
+	} else if IsRaw(p.Name) {
+		score += 2
+	}
+	if strings.Contains(strings.ToUpper(p.Name), "COVER") { // a burst's chosen frame
+		score++
+	}
+	return score
+}
+
+// chooseTop picks the pic best representing a stack: rendered images are
+// preferred over raw files and videos, then earlier pics.
+func chooseTop(pics []*Pic) *Pic {
+	var top *Pic
+	for _, p := range pics {
+		if top == nil || topScore(p) > topScore(top) ||
+			topScore(p) == topScore(top) && (p.Taken.Before(top.Taken) || p.Taken.Equal(top.Taken) && p.id < top.id) {
+			top = p
+		}
+	}
+	return top
+}
+
+// Stack puts pics in a single stack with top as its top pic, merging the
+// stacks any of them are already in.  If top is nil, an existing top is
+// kept or else one is chosen.
+func (l *Lib) Stack(pics []*Pic, top *Pic) error {
+	members := map[int]*Pic{}
+	var list []*Pic
+	oldTop := 0
+	for _, p := range pics {
+		ps, err := p.StackMembers()
+		if err != nil {
+			return err
+		}
+		for _, m := range ps {
+			if members[m.id] == nil {
+				members[m.id] = m
+				list = append(list, m)
+			}
+			if m.Stack == m.id && oldTop == 0 {
+				oldTop = m.id
+			}
+		}
+	}
+	if top != nil && members[top.id] == nil {
+		members[top.id] = top
+		list = append(list, top)
+	}
+	if len(list) < 2 {
+		return errors.New("a stack needs at least two pics")
+	}
+
+	if top == nil {
+		if top = members[oldTop]; top == nil {
+			top = chooseTop(list)
+		}
+	}
+	for _, p := range list {
+		if _, err := l.db.Exec("UPDATE files SET stack=? WHERE id=?;", top.id, p.id); err != nil {
+			return err
+		}
+		p.Stack = top.id
+	}
+	return nil
+}
+
+// Unstack removes p from its stack.  If p was the top, another member is
+// chosen to replace it, and a stack left with one pic is dissolved.
+func (p *Pic) Unstack() error {
+	members, err := p.StackMembers()
+	if err != nil || p.Stack == 0 {
+		return err
+	}
+	var rest []*Pic
+	for _, m := range members {
+		if m.id != p.id {
+			rest = append(rest, m)
+		}
+	}
+
+	if _, err := p.lib.db.Exec("UPDATE files SET stack=0 WHERE id=?;", p.id); err != nil {
+		return err
+	}
+	p.Stack = 0
+	if len(rest) == 1 {
+		_, err = p.lib.db.Exec("UPDATE files SET stack=0 WHERE id=?;", rest[0].id)
+	} else if len(rest) > 1 && members[0].id == p.id {
+		_, err = p.lib.db.Exec("UPDATE files SET stack=? WHERE stack=?;", chooseTop(rest).id, p.id)
+	}
+	return err
+}
+
+// Dissolve unstacks every pic in p's stack.
+func (p *Pic) Dissolve() error {
+	top, err := p.stackTop()
+	if err != nil || top == 0 {
+		return err
+	}
+	_, err = p.lib.db.Exec("UPDATE files SET stack=0 WHERE stack=?;", top)
+	p.Stack = 0
+	return err
+}
+
+// AutoStack stacks p with other pics in the library that are versions of
+// the same shot: files with the same name stem taken within a few seconds
+// (RAW+JPEG pairs and live photos) and frames of the same burst.  If window
+// is not zero, pics taken within window of p are also treated as a burst.
+// It returns the top of p's stack, or nil if p wasn't stacked.
+func (l *Lib) AutoStack(p *Pic, window time.Duration) (*Pic, error) {
+	if p.Taken.IsZero() { // matching names alone aren't enough
+		return nil, nil
+	}
+
+	search := stemWindow
+	if window > search {
+		search = window
+	}
+	burst := BurstID(p.Name)
+	if burst != "" && burstWindow > search {
+		search = burstWindow
+	}
+	from, to := p.Taken.Add(-search).Unix(), p.Taken.Add(search).Unix()
+	s := "SELECT " + piccols + " FROM files WHERE deleted=0 AND id!=? AND taken BETWEEN ? AND ?;"
+	near, err := l.queryPics(s, p.id, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var group []*Pic
+	for _, q := range near {
+		d := q.Taken.Sub(p.Taken)
+		if d < 0 {
+			d = -d
+		}
+		switch {
+		case stem(q.Name) == stem(p.Name) && d <= stemWindow:
+		case burst != "" && BurstID(q.Name) == burst:
+		case window > 0 && d <= window && p.Precision == Exact && q.Precision == Exact:
+		default:
+			continue
+		}
+		group = append(group, q)
+	}
+	if len(group) == 0 {
+		return nil, nil
+	}
+
+	if err := l.Stack(append(group, p), nil); err != nil {
+		return nil, err
+	}
+	top, err := p.stackTop()
+	if err != nil {
+		return nil, err
+	}
+	return l.Open(top)
+}
+
+// CollapseStacks returns pics with each stack represented by just one pic:
+// its top if that is in pics, or else the first of its members that is.
+func CollapseStacks(pics []*Pic) []*Pic {
+	tops := map[int]bool{}
+	for _, p := range pics {
+		if p.Stack != 0 && p.Stack == p.id {
+			tops[p.id] = true
+		}
+	}
+	var collapsed []*Pic
+	seen := map[int]bool{}
+	for _, p := range pics {
+		if p.Stack == 0 {
+			collapsed = append(collapsed, p)
+		} else if tops[p.Stack] && p.Stack == p.id || !tops[p.Stack] && !seen[p.Stack] {
+			collapsed = append(collapsed, p)
+			seen[p.Stack] = true
+		}
+	}
+	return collapsed
+}
@@ -0,0 +1,70 @@
+package piclib
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+// stackedPics adds n distinct pics to l and stacks them with the first as top.
+func stackedPics(t *testing.T, l *Lib, n int) []*Pic {
+	var pics []*Pic
+	for i := 0; i < n; i++ {
+		path := filepath.Join(filepath.Dir(l.Path), fmt.Sprintf("IMG_%04d.jpg", i))
+		writeJPEG(t, path, color.Gray{uint8(40 * i)})
+		p, err := l.AddFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pics = append(pics, p)
+	}
+	if err := l.Stack(pics, pics[0]); err != nil {
+		t.Fatal(err)
+	}
+	return pics
+}
+
+func purge(t *testing.T, l *Lib, p *Pic) {
+	if err := l.Remove(p.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Purge(0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPurgeStackTop(t *testing.T) {
+	l, done := testLib(t)
+	defer done()
+	pics := stackedPics(t, l, 3)
+
+	purge(t, l, pics[0])
+	members, err := pics[1].StackMembers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 {
+		t.Fatalf("got %v stack members after purging the top, want 2", len(members))
+	}
+	for _, m := range members {
+		if m.Stack != members[0].Id || m.Stack == pics[0].Id {
+			t.Errorf("pic %v has stack %v, want the new top %v", m.Id, m.Stack, members[0].Id)
+		}
+	}
+}
+
+func TestPurgeDissolvesStack(t *testing.T) {
+	l, done := testLib(t)
+	defer done()
+	pics := stackedPics(t, l, 2)
+
+	purge(t, l, pics[0])
+	p, err := l.Open(pics[1].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stack != 0 {
+		t.Errorf("remaining pic has stack %v, want it unstacked", p.Stack)
+	}
+}
@@ -0,0 +1,295 @@
+package piclib
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// SyncReport summarizes the changes made by Sync.
+type SyncReport struct {
+	Added     []*Pic // pics copied into the library
+	Updated   []*Pic // existing pics that received new meta data
+	Conflicts []NotesConflict
+}
+
+// NotesConflict describes a pic whose notes were changed independently in
+// both libraries.  The most recently written notes win, but both versions are
+// kept in the pic's meta history.
+type NotesConflict struct {
+	Pic           *Pic
+	Local, Remote string
+}
+
+type metaRow struct {
+	time, field, value string
+}
+
+// Sync merges every pic in src (except those in its trash) into l.  Files
+// missing from l are copied and verified, meta history is merged by
+// timestamp, and columns without history (rating, flag, label, hidden) are
+// only filled in where l has no value.  Sync is idempotent and can safely be
+// re-run after being interrupted.
+func (l *Lib) Sync(src *Lib) (*SyncReport, error) {
+	pics, err := src.queryPics("SELECT " + piccols + " FROM files WHERE deleted=0 ORDER BY id;")
+	if err != nil {
+		return nil, err
+	}
+
+	rep := &SyncReport{}
+	for _, sp := range pics {
+		var id int
+		added := false
+		err := l.db.QueryRow("SELECT id FROM files WHERE sum=?;", sp.Sum).Scan(&id)
+		if err == sql.ErrNoRows {
+			if id, err = l.syncFile(sp); err != nil {
+				return rep, fmt.Errorf("pic %v (%v): %v", sp.Id, sp.Name, err)
+			}
+			added = true
+		} else if err != nil {
+			return rep, err
+		}
+
+		p, err := l.Open(id)
+		if err != nil {
+			return rep, err
+		}
+		changed, err := p.syncMeta(sp, added, rep)
+		if err != nil {
+			return rep, fmt.Errorf("pic %v (%v): %v", sp.Id, sp.Name, err)
+		}
+		if added {
+			rep.Added = append(rep.Added, p)
+		} else if changed {
+			rep.Updated = append(rep.Updated, p)
+		}
+	}
+	return rep, nil
+}
+
+// syncFile copies sp's file into the library and inserts its row, returning
+// the new pic id.  A partially copied file is replaced.
+func (l *Lib) syncFile(sp *Pic) (id int, err error) {
+	dst := filepath.Join(l.Path, diskname(sp.Name, sp.Sum))
+	if err := verifyFile(dst, sp.Sum); err != nil {
+		if err := copyVerified(sp.Filepath(), dst, sp.Sum); err != nil {
+			return 0, err
+		}
+	}
+
+	thumb, err := sp.Thumb()
+	if err != nil {
+		thumb = nil // rebuilt from the original when first needed
+	}
+	thumbkey, err := l.cacheThumb(thumb)
+	if err != nil {
+		return 0, err
+	}
+
+	s := "INSERT INTO files (sum,name,added,taken,orient,thumbkey,precision,takenend,rating,flag,label,hidden) VALUES (?,?,?,?,?,?,?,?,?,?,?,?);"
+	res, err := l.db.Exec(s, sp.Sum, sp.Name, sp.Added.Unix(), sp.Taken.Unix(), sp.Orient, thumbkey,
+		sp.Precision, sp.TakenEnd.Unix(), sp.Rating, sp.Flag, sp.Label, sp.Hidden)
+	if err != nil {
+		return 0, err
+	}
+	id64, err := res.LastInsertId()
+	return int(id64), err
+}
+
+func verifyFile(path string, sum []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	got, err := Sha256(f)
+	if err != nil {
+		return err
+	} else if !bytes.Equal(got, sum) {
+		return fmt.Errorf("%v failed checksum validation", path)
+	}
+	return nil
+}
+
+// copyVerified copies src to dst via a temporary file that is only renamed
+// into place once its checksum has been verified.
+func copyVerified(src, dst string, sum []byte) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return writeVerified(r, dst, sum)
+}
+
+// writeVerified is like copyVerified but reads the content from r.
+func writeVerified(r io.Reader, dst string, sum []byte) error {
+	tmp := dst + ".part"
+	w, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	_, err = io.Copy(w, r)
+	if err1 := w.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	if err := verifyFile(tmp, sum); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0444); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst) // atomically replaces any corrupt copy
+}
+
+func metaRows(p *Pic) ([]metaRow, error) {
+	rows, err := p.lib.db.Query("SELECT time,field,value FROM meta WHERE id=? ORDER BY time;", p.id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var meta []metaRow
+	for rows.Next() {
+		var m metaRow
+		if err := rows.Scan(&m.time, &m.field, &m.value); err != nil {
+			return nil, err
+		}
+		meta = append(meta, m)
+	}
+	return meta, rows.Err()
+}
+
+// latest returns the most recent row for field (and false if there is none).
+func latest(meta []metaRow, field string) (metaRow, bool) {
+	var last metaRow
+	found := false
+	for _, m := range meta {
+		if m.field == field && (!found || m.time >= last.time) {
+			last, found = m, true
+		}
+	}
+	return last, found
+}
+
+// syncMeta merges sp's meta history and unset columns into p.  fresh is true
+// if p was just copied from sp, in which case its columns and thumb are
+// already up to date.  It returns true if anything changed.
+func (p *Pic) syncMeta(sp *Pic, fresh bool, rep *SyncReport) (changed bool, err error) {
+	local, err := metaRows(p)
+	if err != nil {
+		return false, err
+	}
+	remote, err := metaRows(sp)
+	if err != nil {
+		return false, err
+	}
+
+	have := map[metaRow]bool{}
+	for _, m := range local {
+		have[m] = true
+	}
+	theirs := map[metaRow]bool{}
+	for _, m := range remote {
+		theirs[m] = true
+	}
+
+	// notes conflict if each side's current notes are unknown to the other
+	ln, lok := latest(local, NotesField)
+	rn, rok := latest(remote, NotesField)
+	if lok && rok && ln.value != rn.value && !have[rn] && !theirs[ln] {
+		rep.Conflicts = append(rep.Conflicts, NotesConflict{Pic: p, Local: ln.value, Remote: rn.value})
+	}
+
+	before := map[string]string{}
+	for _, f := range []string{TakenField, OrientField, EditsField} {
+		m, _ := latest(local, f)
+		before[f] = m.value
+	}
+
+	for _, m := range remote {
+		if have[m] {
+			continue
+		}
+		s := "INSERT INTO meta (id,time,field,value) VALUES (?,?,?,?);"
+		if _, err := p.lib.db.Exec(s, p.id, m.time, m.field, m.value); err != nil {
+			return false, err
+		}
+		local = append(local, m)
+		have[m] = true
+		changed = true
+	}
+
+	if fresh {
+		return changed, nil
+	}
+
+	// keep columns derived from meta history consistent with it
+	rebuild := false
+	if m, ok := latest(local, TakenField); ok && m.value != before[TakenField] {
+		d, err := ParseDate(m.value)
+		if err != nil {
+			return changed, err
+		}
+		s := "UPDATE files SET taken=?,takenend=?,precision=? WHERE id=?;"
+		if _, err := p.lib.db.Exec(s, d.Start.Unix(), d.End.Unix(), d.Precision, p.id); err != nil {
+			return changed, err
+		}
+		p.Taken, p.TakenEnd, p.Precision = d.Start, d.End, d.Precision
+	}
+	if m, ok := latest(local, OrientField); ok && m.value != before[OrientField] {
+		orient, err := strconv.Atoi(m.value)
+		if err != nil {
+			return changed, err
+		}
+		if _, err := p.lib.db.Exec("UPDATE files SET orient=? WHERE id=?;", orient, p.id); err != nil {
+			return changed, err
+		}
+		p.Orient, rebuild = orient, true
+	}
+	if m, _ := latest(local, EditsField); m.value != before[EditsField] {
+		rebuild = true
+	}
+	if rebuild {
+		if err := p.RebuildThumb(); err != nil {
+			return changed, err
+		}
+	}
+
+	// columns without history only fill in unset values
+	if p.Rating == 0 && sp.Rating != 0 {
+		if err := p.SetRating(sp.Rating); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	if p.Flag == Unflagged && sp.Flag != Unflagged {
+		if err := p.SetFlag(sp.Flag); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	if p.Label == "" && sp.Label != "" {
+		if err := p.SetLabel(sp.Label); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	if !p.Hidden && sp.Hidden {
+		if err := p.SetHidden(true); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
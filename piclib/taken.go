@@ -0,0 +1,238 @@
+package piclib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// DateSource is one method of determining when a picture was taken.  name is
+// the file's original name and f is positioned at the start of its content.
+type DateSource struct {
+	Name string
+	Func func(name string, f *os.File) (time.Time, error)
+}
+
+// DefaultDateSources are tried in order when a Lib's DateSources is nil.
+var DefaultDateSources = []DateSource{
+	{"exif", exifDate},
+	{"filename", filenameDate},
+	{"xmp", xmpDate},
+	{"video", videoDate},
+	{"mtime", mtimeDate},
+}
+
+// LookupDateSource returns the default date source with the given name.
+func LookupDateSource(name string) (DateSource, bool) {
+	for _, src := range DefaultDateSources {
+		if src.Name == name {
+			return src, true
+		}
+	}
+	return DateSource{}, false
+}
+
+var errNoDate = errors.New("no date found")
+
+// InferTaken tries each of the library's date sources in order and returns
+// the first time found along with the name of the source that provided it.
+func (l *Lib) InferTaken(name string, f *os.File) (t time.Time, src string, err error) {
+	srcs := l.DateSources
+	if srcs == nil {
+		srcs = DefaultDateSources
+	}
+
+	for _, ds := range srcs {
+		if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+			return time.Time{}, "", err
+		}
+		t, err := ds.Func(name, f)
+		if err == nil && !t.IsZero() {
+			return t, ds.Name, nil
+		}
+	}
+	return time.Time{}, "", errNoDate
+}
+
+var exifTimeFields = []exif.FieldName{exif.DateTimeOriginal, exif.DateTimeDigitized, exif.DateTime}
+
+func exifDate(name string, f *os.File) (time.Time, error) {
+	x, err := decodeExif(name, f)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, field := range exifTimeFields {
+		tag, err := x.Get(field)
+		if err != nil {
+			continue
+		}
+		s, err := tag.StringVal()
+		if err != nil {
+			continue
+		}
+		t, err := time.ParseInLocation("2006:01:02 15:04:05", strings.TrimRight(s, "\x00 "), time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errNoDate
+}
+
+// filenamePats match dates embedded in names such as IMG_20190704_120000.jpg,
+// Screenshot_2020-01-01-10-30-00.png or "WhatsApp Image 2020-01-01 at
+// 12.00.00.jpeg".  The time portion is optional.
+var filenamePats = []*regexp.Regexp{
+	regexp.MustCompile(`(?:^|[^0-9])((?:19|20)[0-9]{2})([01][0-9])([0-3][0-9])[_\- T]?([0-2][0-9])([0-5][0-9])([0-5][0-9])`),
+	regexp.MustCompile(`(?:^|[^0-9])((?:19|20)[0-9]{2})[\-_.]([01][0-9])[\-_.]([0-3][0-9])(?:(?:[_\- T]|[_ ]at[_ ])([0-2][0-9])[\-_.:]([0-5][0-9])[\-_.:]([0-5][0-9]))?`),
+	regexp.MustCompile(`(?:^|[^0-9])((?:19|20)[0-9]{2})([01][0-9])([0-3][0-9])(?:[^0-9]|$)`),
+}
+
+func filenameDate(name string, f *os.File) (time.Time, error) {
+	base := filepath.Base(name)
+	for _, pat := range filenamePats {
+		m := pat.FindStringSubmatch(base)
+		if m == nil {
+			continue
+		}
+
+		var v [6]int
+		for i, s := range m[1:] {
+			if s != "" {
+				v[i], _ = strconv.Atoi(s)
+			}
+		}
+		if v[1] < 1 || v[1] > 12 || v[2] < 1 || v[2] > 31 || v[3] > 23 {
+			continue
+		}
+		// time.Date normalizes impossible dates like Feb 31 into real ones
+		t := time.Date(v[0], time.Month(v[1]), v[2], v[3], v[4], v[5], 0, time.Local)
+		if y, mon, d := t.Date(); y != v[0] || int(mon) != v[1] || d != v[2] {
+			continue
+		}
+		return t, nil
+	}
+	return time.Time{}, errNoDate
+}
+
+var isoLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func parseISO(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range isoLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date format '%v'", s)
+}
+
+// xmpDate looks for sidecars next to the file actually being read rather than
+// its original name, which may be relative to some other directory.
+func xmpDate(name string, f *os.File) (time.Time, error) {
+	x, err := readXMP(f.Name(), f)
+	if x == nil && err != nil {
+		return time.Time{}, err
+	} else if x == nil || x.Created.IsZero() {
+		return time.Time{}, errNoDate
+	}
+	return x.Created, nil
+}
+
+// mp4Epoch is the reference time for QuickTime/MP4 atom timestamps.
+var mp4Epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// videoDate reads the creation time from the movie header (mvhd) atom of
+// QuickTime and MP4 files.
+func videoDate(name string, f *os.File) (time.Time, error) {
+	var hdr [8]byte
+	for { // walk top-level atoms looking for moov
+		if _, err := io.ReadFull(f, hdr[:]); err != nil {
+			return time.Time{}, errNoDate
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		typ := string(hdr[4:])
+		hdrlen := int64(8)
+		if size == 1 {
+			var ext [8]byte
+			if _, err := io.ReadFull(f, ext[:]); err != nil {
+				return time.Time{}, errNoDate
+			}
+			size = int64(binary.BigEndian.Uint64(ext[:]))
+			hdrlen = 16
+		} else if size == 0 {
+			return time.Time{}, errNoDate
+		}
+		if size < hdrlen {
+			return time.Time{}, errNoDate
+		}
+
+		if typ == "moov" {
+			return mvhdDate(io.LimitReader(f, size-hdrlen))
+		}
+		if _, err := f.Seek(size-hdrlen, os.SEEK_CUR); err != nil {
+			return time.Time{}, err
+		}
+	}
+}
+
+func mvhdDate(r io.Reader) (time.Time, error) {
+	var hdr [8]byte
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return time.Time{}, errNoDate
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		if size < 8 {
+			return time.Time{}, errNoDate
+		} else if !bytes.Equal(hdr[4:], []byte("mvhd")) {
+			if _, err := io.CopyN(ioutil.Discard, r, size-8); err != nil {
+				return time.Time{}, errNoDate
+			}
+			continue
+		}
+
+		// version(1) flags(3) creation time(4 or 8)
+		var body [12]byte
+		if _, err := io.ReadFull(r, body[:]); err != nil {
+			return time.Time{}, errNoDate
+		}
+		secs := uint64(binary.BigEndian.Uint32(body[4:8]))
+		if body[0] == 1 {
+			secs = binary.BigEndian.Uint64(body[4:12])
+		}
+		if secs == 0 {
+			return time.Time{}, errNoDate
+		}
+		return mp4Epoch.Add(time.Duration(secs) * time.Second).Local(), nil
+	}
+}
+
+func mtimeDate(name string, f *os.File) (time.Time, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
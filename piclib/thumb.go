@@ -0,0 +1,200 @@
+package piclib
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// Decoding limits used when a Lib's are zero.
+const (
+	DefaultMaxPixels    = 250000000 // 250 megapixels
+	DefaultDecodeMemory = 1 << 30
+)
+
+// TooLargeErr is returned instead of decoding images over a Lib's MaxPixels.
+type TooLargeErr struct {
+	Name          string
+	Width, Height int
+	Max           int
+}
+
+func (e TooLargeErr) Error() string {
+	return fmt.Sprintf("%v is %vx%v pixels - over the limit of %v megapixels", e.Name, e.Width, e.Height, e.Max/1000000)
+}
+
+// memBudget limits the combined memory estimated to be used by concurrent
+// image decodes.
+type memBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	total int64
+	used  int64
+}
+
+func newMemBudget(total int64) *memBudget {
+	b := &memBudget{total: total}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire waits until n bytes are available.  Requests larger than the
+// whole budget wait until nothing else is using it.
+func (b *memBudget) acquire(n int64) int64 {
+	if n > b.total {
+		n = b.total
+	}
+	b.mu.Lock()
+	for b.used+n > b.total {
+		b.cond.Wait()
+	}
+	b.used += n
+	b.mu.Unlock()
+	return n
+}
+
+func (b *memBudget) release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+func (l *Lib) maxPixels() int {
+	if l.MaxPixels > 0 {
+		return l.MaxPixels
+	}
+	return DefaultMaxPixels
+}
+
+func (l *Lib) memBudget() *memBudget {
+	l.budgetOnce.Do(func() {
+		total := l.DecodeMemory
+		if total <= 0 {
+			total = DefaultDecodeMemory
+		}
+		l.budget = newMemBudget(total)
+	})
+	return l.budget
+}
+
+// bytesPerPixel estimates the memory used by a decoded image in model.
+func bytesPerPixel(model color.Model) int64 {
+	switch model {
+	case color.GrayModel, color.AlphaModel:
+		return 1
+	case color.Gray16Model, color.Alpha16Model:
+		return 2
+	case color.YCbCrModel:
+		return 3 // 4:4:4 worst case
+	case color.RGBA64Model, color.NRGBA64Model:
+		return 8
+	}
+	return 4
+}
+
+// decodeConfig returns the dimensions and color model of the image of the
+// file called name.
+func decodeConfig(name string, r io.Reader) (image.Config, error) {
+	if f, ok := LookupFormat(name); ok && f.Decode == nil {
+		_, err := decodeImage(name, r)
+		return image.Config{}, err
+	} else if ok && f.DecodeConfig != nil {
+		return f.DecodeConfig(r)
+	}
+	cfg, _, err := image.DecodeConfig(r)
+	return cfg, err
+}
+
+// decodeBounded decodes the image of the file called name from f once its
+// pixel count is known to be within limits and enough of the library's
+// decoding memory budget is available.  extra is memory needed per pixel
+// after decoding, e.g. for transforms.  release must be called once the
+// image is no longer used.
+func (l *Lib) decodeBounded(name string, f io.ReadSeeker, extra int64) (img image.Image, release func(), err error) {
+	cfg, err := decodeConfig(name, f)
+	if err != nil {
+		return nil, nil, err
+	}
+	if px := cfg.Width * cfg.Height; px > l.maxPixels() || cfg.Width < 0 || cfg.Height < 0 {
+		return nil, nil, TooLargeErr{name, cfg.Width, cfg.Height, l.maxPixels()}
+	}
+
+	b := l.memBudget()
+	n := b.acquire(int64(cfg.Width) * int64(cfg.Height) * (bytesPerPixel(cfg.ColorModel) + extra))
+	release = func() { b.release(n) }
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		release()
+		return nil, nil, err
+	}
+	img, err = decodeImage(name, f)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return img, release, nil
+}
+
+// exifThumb returns the thumbnail embedded in the EXIF data of f if it is at
+// least w by h pixels (a zero dimension isn't checked).  If any is true, a
+// thumbnail of any size is returned.
+func exifThumb(name string, f io.ReadSeeker, w, h int, any bool) (image.Image, bool) {
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		return nil, false
+	}
+	x, err := exif.Decode(f)
+	if err != nil {
+		return nil, false
+	}
+	data, err := x.JpegThumbnail()
+	if err != nil {
+		return nil, false
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil || !any && (cfg.Width < w || cfg.Height < h) {
+		return nil, false
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	return img, err == nil
+}
+
+// thumbnail makes a thumbnail for the file called name with content f while
+// bounding memory use: the embedded EXIF thumbnail is used if it is large
+// enough (or if the image is too large to decode), and otherwise decoding
+// waits for the library's memory budget.
+func (l *Lib) thumbnail(name string, f io.ReadSeeker, orient int) ([]byte, error) {
+	w, h := l.thumbSize()
+	minw, minh := w, h
+	if orient >= 5 { // rotated a quarter turn
+		minw, minh = h, w
+	}
+	if img, ok := exifThumb(name, f, minw, minh, false); ok {
+		return thumbImage(img, w, h, orient)
+	}
+
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+	var extra int64
+	if orient > 1 {
+		extra = 4 // the oriented copy
+	}
+	img, release, err := l.decodeBounded(name, f, extra)
+	if _, ok := err.(TooLargeErr); ok {
+		if img, ok := exifThumb(name, f, 0, 0, true); ok {
+			return thumbImage(img, w, h, orient)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return thumbImage(img, w, h, orient)
+}
@@ -0,0 +1,94 @@
+package piclib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Remove moves the pic's file into the library's trash directory and marks it
+// deleted.  Deleted pics are excluded from listings until they are restored
+// or purged.
+func (l *Lib) Remove(id int) error {
+	p, err := l.Open(id)
+	if err != nil {
+		return err
+	} else if !p.Deleted.IsZero() {
+		return fmt.Errorf("pic %v is already in the trash", id)
+	}
+
+	if err := os.MkdirAll(filepath.Join(l.Path, TrashDir), 0755); err != nil {
+		return err
+	}
+
+	src := p.Filepath()
+	now := time.Now()
+	p.Deleted = now
+	if err := os.Rename(src, p.Filepath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	_, err = l.db.Exec("UPDATE files SET deleted=? WHERE id=?;", now.Unix(), id)
+	return err
+}
+
+// Restore moves a pic out of the trash and back into the library.
+func (l *Lib) Restore(id int) error {
+	p, err := l.Open(id)
+	if err != nil {
+		return err
+	} else if p.Deleted.IsZero() {
+		return fmt.Errorf("pic %v is not in the trash", id)
+	}
+
+	src := p.Filepath()
+	p.Deleted = time.Time{}
+	if err := os.Rename(src, p.Filepath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	_, err = l.db.Exec("UPDATE files SET deleted=0 WHERE id=?;", id)
+	return err
+}
+
+// ListTrash returns the pics in the trash, most recently deleted first.
+func (l *Lib) ListTrash() (pics []*Pic, err error) {
+	s := "SELECT " + piccols + " FROM files WHERE deleted!=0 ORDER BY deleted DESC;"
+	return l.queryPics(s)
+}
+
+// Purge permanently deletes pics that have been in the trash for longer than
+// age along with all of their meta data.
+func (l *Lib) Purge(age time.Duration) (purged []*Pic, err error) {
+	s := "SELECT " + piccols + " FROM files WHERE deleted!=0 AND deleted<=? ORDER BY deleted;"
+	pics, err := l.queryPics(s, time.Now().Add(-age).Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range pics {
+		if err := os.Remove(p.Filepath()); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		if err := os.Remove(p.ParityPath()); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		if _, err := l.db.Exec("DELETE FROM meta WHERE id=?;", p.id); err != nil {
+			return purged, err
+		}
+		// versions derived from p now derive from p's parent
+		if _, err := l.db.Exec("UPDATE files SET parent=? WHERE parent=?;", p.Parent, p.id); err != nil {
+			return purged, err
+		}
+		// the rest of p's stack gets a new top or is dissolved
+		if err := p.Unstack(); err != nil {
+			return purged, err
+		}
+		if _, err := l.db.Exec("DELETE FROM files WHERE id=?;", p.id); err != nil {
+			return purged, err
+		}
+		purged = append(purged, p)
+	}
+	return purged, nil
+}
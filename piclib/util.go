@@ -4,9 +4,7 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"image"
-	_ "image/gif"
 	"image/jpeg"
-	_ "image/png"
 	"io"
 	"os"
 	"path/filepath"
@@ -23,6 +21,19 @@ func DefaultPath() string {
 	return filepath.Join(os.Getenv("HOME"), ".piclib")
 }
 
+// reserved are the names of library directory entries that are not pic files.
+var reserved = map[string]bool{
+	Libname:       true,
+	TrashDir:      true,
+	BackupKeyName: true,
+	ParityDir:     true,
+	CacheDir:      true,
+}
+
+// IsReserved returns true if name is an entry in the library directory that
+// is managed by piclib rather than being a pic's file.
+func IsReserved(name string) bool { return reserved[name] }
+
 func Sha256(r io.Reader) (sum []byte, err error) {
 	h := sha256.New()
 	_, err = io.Copy(h, r)
@@ -38,9 +49,23 @@ func MakeThumb(r io.Reader, w, h int, orient int) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	return thumbImage(img, w, h, orient)
+}
 
-	m := resize.Resize(uint(w), uint(h), img, resize.Bicubic)
+func thumbImage(img image.Image, w, h int, orient int) ([]byte, error) {
+	m := resize.Resize(uint(w), uint(h), orientImage(img, orient), resize.Bicubic)
 
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, m, nil)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// orientImage transforms img so that it displays upright given its EXIF
+// orientation.
+func orientImage(m image.Image, orient int) image.Image {
 	switch orient {
 	case 3, 4:
 		m = imaging.Rotate180(m)
@@ -54,11 +79,5 @@ func MakeThumb(r io.Reader, w, h int, orient int) ([]byte, error) {
 	case 2, 5, 4, 7:
 		m = imaging.FlipH(m)
 	}
-
-	var buf bytes.Buffer
-	err = jpeg.Encode(&buf, m, nil)
-	if err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
+	return m
 }
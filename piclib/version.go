@@ -0,0 +1,159 @@
+package piclib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnchanged is returned when a checked out file is imported without having
+// been modified.
+var ErrUnchanged = errors.New("file is unchanged from the original")
+
+// Checkout copies the pic's original file into dir as a writable file for
+// editing with other programs, returning its path.
+func (p *Pic) Checkout(dir string) (string, error) {
+	ext := filepath.Ext(p.Name)
+	base := strings.TrimSuffix(p.Name, ext) + "-edit"
+	path := filepath.Join(dir, base+ext)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%v%v%v", base, i, ext))
+	}
+
+	rc, err := p.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, rc)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// AddVersion imports the file at path as a new version of parent - usually
+// the result of editing a checked out copy.  The version inherits the
+// parent's notes, tags, hidden state, rating, flag and label, and its taken
+// date unless the file has its own EXIF date.
+func (l *Lib) AddVersion(parent *Pic, path string) (*Pic, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := Sha256(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	} else if bytes.Equal(sum, parent.Sum) {
+		return nil, ErrUnchanged
+	}
+
+	p, err := l.AddFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := l.db.Exec("UPDATE files SET parent=? WHERE id=?;", parent.id, p.id); err != nil {
+		return nil, err
+	}
+	p.Parent = parent.id
+
+	// a version of a private pic must not show up in default listings
+	if parent.Hidden {
+		if err := p.SetHidden(true); err != nil {
+			return nil, err
+		}
+	}
+	if parent.Rating != 0 {
+		if err := p.SetRating(parent.Rating); err != nil {
+			return nil, err
+		}
+	}
+	if parent.Flag != Unflagged {
+		if err := p.SetFlag(parent.Flag); err != nil {
+			return nil, err
+		}
+	}
+	if parent.Label != "" {
+		if err := p.SetLabel(parent.Label); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range []string{NotesField, TagsField} {
+		val, err := parent.GetMeta(field)
+		if err != nil {
+			return nil, err
+		} else if val == "" {
+			continue
+		}
+		if err := p.SetMeta(field, val); err != nil {
+			return nil, err
+		}
+	}
+
+	src, err := p.GetMeta(TakenSourceField)
+	if err != nil {
+		return nil, err
+	} else if src == "exif" {
+		return p, nil
+	}
+	if err := p.SetDate(parent.When()); err != nil {
+		return nil, err
+	}
+	src, err = parent.GetMeta(TakenSourceField)
+	if err != nil {
+		return nil, err
+	}
+	return p, p.SetMeta(TakenSourceField, src)
+}
+
+// Versions returns the pic's version history: the original pic it was
+// derived from followed by its versions, each after the pic it was derived
+// from.  Pics without versions are returned alone.
+func (p *Pic) Versions() ([]*Pic, error) {
+	root := p
+	for seen := map[int]bool{}; root.Parent != 0 && !seen[root.id]; {
+		seen[root.id] = true
+		parent, err := p.lib.Open(root.Parent)
+		if err != nil {
+			break // purged without relinking
+		}
+		root = parent
+	}
+
+	versions := []*Pic{root}
+	queue := []int{root.id}
+	seen := map[int]bool{root.id: true}
+	for len(queue) > 0 {
+		s := "SELECT " + piccols + " FROM files WHERE parent=? ORDER BY added, id;"
+		children, err := p.lib.queryPics(s, queue[0])
+		if err != nil {
+			return nil, err
+		}
+		queue = queue[1:]
+		for _, c := range children {
+			if !seen[c.id] {
+				seen[c.id] = true
+				versions = append(versions, c)
+				queue = append(queue, c.id)
+			}
+		}
+	}
+	return versions, nil
+}
@@ -0,0 +1,42 @@
+package piclib
+
+import (
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddVersionInherits(t *testing.T) {
+	l, done := testLib(t)
+	defer done()
+
+	dir := filepath.Dir(l.Path)
+	writeJPEG(t, filepath.Join(dir, "orig.jpg"), color.White)
+	parent, err := l.AddFile(filepath.Join(dir, "orig.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{parent.SetHidden(true), parent.SetRating(4), parent.SetFlag(Picked), parent.SetLabel("red")} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeJPEG(t, filepath.Join(dir, "edited.jpg"), color.Black)
+	v, err := l.AddVersion(parent, filepath.Join(dir, "edited.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err = l.Open(v.Id); err != nil {
+		t.Fatal(err)
+	}
+	if !v.Hidden {
+		t.Error("version of a hidden pic is not hidden")
+	}
+	if v.Rating != 4 || v.Flag != Picked || v.Label != "red" {
+		t.Errorf("got rating/flag/label %v/%v/%v, want 4/%v/red", v.Rating, v.Flag, v.Label, Picked)
+	}
+	if v.Parent != parent.Id {
+		t.Errorf("got parent %v, want %v", v.Parent, parent.Id)
+	}
+}
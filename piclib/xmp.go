@@ -0,0 +1,352 @@
+package piclib
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	rdfNS  = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	dcNS   = "http://purl.org/dc/elements/1.1/"
+	xmpNS  = "http://ns.adobe.com/xap/1.0/"
+	exifNS = "http://ns.adobe.com/exif/1.0/"
+	psNS   = "http://ns.adobe.com/photoshop/1.0/"
+)
+
+// XMP holds the subset of XMP metadata that piclib understands.
+type XMP struct {
+	Subject     []string // dc:subject keywords
+	Description string   // dc:description
+	Rating      int      // xmp:Rating (-1 for rejected, 0 for unrated, 1-5)
+	Label       string   // xmp:Label color label
+	Created     time.Time
+	HasGPS      bool
+	Lat, Lon    float64
+
+	hasLat, hasLon bool // HasGPS needs both coordinates
+}
+
+// ParseXMP reads an XMP packet (either a sidecar file or a packet extracted
+// from an image).
+func ParseXMP(r io.Reader) (*XMP, error) {
+	x := &XMP{}
+	d := xml.NewDecoder(r)
+
+	var prop xml.Name // current property element inside an rdf:Description
+	var text bytes.Buffer
+	depth, descDepth := 0, 0
+	haslist := false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			text.Reset()
+			if t.Name.Space == rdfNS && t.Name.Local == "Description" && prop.Local == "" {
+				descDepth = depth
+				for _, a := range t.Attr {
+					x.set(a.Name, a.Value)
+				}
+			} else if t.Name.Space == rdfNS && t.Name.Local == "li" {
+				haslist = true
+			} else if descDepth > 0 && depth == descDepth+1 {
+				prop, haslist = t.Name, false
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if t.Name.Space == rdfNS && t.Name.Local == "li" {
+				x.set(prop, text.String())
+			} else if depth == descDepth+1 && t.Name == prop {
+				if !haslist {
+					x.set(prop, text.String())
+				}
+				prop = xml.Name{}
+			} else if depth == descDepth {
+				descDepth = 0
+			}
+			text.Reset()
+			depth--
+		}
+	}
+	x.HasGPS = x.hasLat && x.hasLon
+	return x, nil
+}
+
+func (x *XMP) set(name xml.Name, val string) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return
+	}
+
+	switch name {
+	case xml.Name{Space: dcNS, Local: "subject"}:
+		x.Subject = append(x.Subject, val)
+	case xml.Name{Space: dcNS, Local: "description"}:
+		if x.Description == "" { // first (x-default) alternative wins
+			x.Description = val
+		}
+	case xml.Name{Space: xmpNS, Local: "Rating"}:
+		if v, err := strconv.ParseFloat(val, 64); err == nil {
+			// clamp out of spec ratings rather than failing imports on them
+			x.Rating = int(math.Max(-1, math.Min(5, v)))
+		}
+	case xml.Name{Space: xmpNS, Local: "Label"}:
+		x.Label = strings.ToLower(val)
+	case xml.Name{Space: xmpNS, Local: "CreateDate"},
+		xml.Name{Space: exifNS, Local: "DateTimeOriginal"},
+		xml.Name{Space: psNS, Local: "DateCreated"}:
+		if t, err := parseISO(val); err == nil && x.Created.IsZero() {
+			x.Created = t
+		}
+	case xml.Name{Space: exifNS, Local: "GPSLatitude"}:
+		if v, err := parseXMPCoord(val); err == nil {
+			x.Lat, x.hasLat = v, true
+		}
+	case xml.Name{Space: exifNS, Local: "GPSLongitude"}:
+		if v, err := parseXMPCoord(val); err == nil {
+			x.Lon, x.hasLon = v, true
+		}
+	}
+}
+
+// parseXMPCoord parses XMP GPS coordinates of the form "DDD,MM,SSk" or
+// "DDD,MM.mmk" where k is one of N, S, E or W.
+func parseXMPCoord(s string) (float64, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid xmp coordinate '%v'", s)
+	}
+	dir := s[len(s)-1]
+	parts := strings.Split(s[:len(s)-1], ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid xmp coordinate '%v'", s)
+	}
+
+	v := 0.0
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid xmp coordinate '%v'", s)
+		}
+		v += f / math.Pow(60, float64(i))
+	}
+
+	switch dir {
+	case 'S', 's', 'W', 'w':
+		v = -v
+	case 'N', 'n', 'E', 'e':
+	default:
+		return 0, fmt.Errorf("invalid xmp coordinate '%v'", s)
+	}
+	return v, nil
+}
+
+func formatXMPCoord(v float64, pos, neg byte) string {
+	dir := pos
+	if v < 0 {
+		dir, v = neg, -v
+	}
+	deg := math.Floor(v)
+	return fmt.Sprintf("%v,%.6f%c", deg, (v-deg)*60, dir)
+}
+
+// WriteTo writes x as a standalone XMP sidecar packet.
+func (x *XMP) WriteTo(w io.Writer) (n int64, err error) {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>` + "\n")
+	buf.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	buf.WriteString(` <rdf:RDF xmlns:rdf="` + rdfNS + `">` + "\n")
+	buf.WriteString(`  <rdf:Description rdf:about=""`)
+	fmt.Fprintf(&buf, "\n    xmlns:dc=%q\n    xmlns:xmp=%q\n    xmlns:exif=%q", dcNS, xmpNS, exifNS)
+	if x.Rating != 0 {
+		fmt.Fprintf(&buf, "\n    xmp:Rating=\"%d\"", x.Rating)
+	}
+	if x.Label != "" {
+		fmt.Fprintf(&buf, "\n    xmp:Label=%q", strings.ToUpper(x.Label[:1])+x.Label[1:])
+	}
+	if !x.Created.IsZero() {
+		fmt.Fprintf(&buf, "\n    xmp:CreateDate=%q", x.Created.Format("2006-01-02T15:04:05"))
+	}
+	if x.HasGPS {
+		fmt.Fprintf(&buf, "\n    exif:GPSLatitude=%q", formatXMPCoord(x.Lat, 'N', 'S'))
+		fmt.Fprintf(&buf, "\n    exif:GPSLongitude=%q", formatXMPCoord(x.Lon, 'E', 'W'))
+	}
+	buf.WriteString(">\n")
+
+	if x.Description != "" {
+		buf.WriteString("   <dc:description>\n    <rdf:Alt>\n     <rdf:li xml:lang=\"x-default\">")
+		xml.EscapeText(&buf, []byte(x.Description))
+		buf.WriteString("</rdf:li>\n    </rdf:Alt>\n   </dc:description>\n")
+	}
+	if len(x.Subject) > 0 {
+		buf.WriteString("   <dc:subject>\n    <rdf:Bag>\n")
+		for _, s := range x.Subject {
+			buf.WriteString("     <rdf:li>")
+			xml.EscapeText(&buf, []byte(s))
+			buf.WriteString("</rdf:li>\n")
+		}
+		buf.WriteString("    </rdf:Bag>\n   </dc:subject>\n")
+	}
+
+	buf.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n")
+	buf.WriteString(`<?xpacket end="w"?>` + "\n")
+	return buf.WriteTo(w)
+}
+
+// xmpScanLen limits how much of a file is searched for an embedded XMP packet.
+const xmpScanLen = 1 << 20
+
+var (
+	xmpStart = []byte("<x:xmpmeta")
+	xmpEnd   = []byte("</x:xmpmeta>")
+)
+
+// EmbeddedXMP extracts and parses an XMP packet embedded near the start of an
+// image file (e.g. in a JPEG APP1 segment).  It returns nil if none is found.
+func EmbeddedXMP(r io.Reader) (*XMP, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, xmpScanLen))
+	if err != nil {
+		return nil, err
+	}
+
+	start := bytes.Index(data, xmpStart)
+	if start < 0 {
+		return nil, nil
+	}
+	end := bytes.Index(data[start:], xmpEnd)
+	if end < 0 {
+		return nil, nil
+	}
+	return ParseXMP(bytes.NewReader(data[start : start+end+len(xmpEnd)]))
+}
+
+// SidecarPaths returns the candidate XMP sidecar paths for an image file -
+// both the "photo.jpg.xmp" and "photo.xmp" conventions.
+func SidecarPaths(path string) []string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	return []string{path + ".xmp", path + ".XMP", base + ".xmp", base + ".XMP"}
+}
+
+// readXMP returns the embedded XMP metadata in f merged with (and overridden
+// by) any sidecar next to path.  Malformed packets are skipped and reported
+// in err along with whatever metadata could be read.
+func readXMP(path string, f *os.File) (x *XMP, err error) {
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+	x, err = EmbeddedXMP(f)
+	if err != nil {
+		x, err = nil, fmt.Errorf("invalid embedded xmp: %v", err)
+	}
+
+	for _, sc := range SidecarPaths(path) {
+		sf, err1 := os.Open(sc)
+		if err1 != nil {
+			continue
+		}
+		side, err1 := ParseXMP(sf)
+		sf.Close()
+		if err1 != nil {
+			return x, fmt.Errorf("invalid xmp sidecar %v: %v", sc, err1)
+		} else if x == nil {
+			return side, err
+		}
+		x.merge(side)
+		return x, err
+	}
+	return x, err
+}
+
+func (x *XMP) merge(o *XMP) {
+	if len(o.Subject) > 0 {
+		x.Subject = o.Subject
+	}
+	if o.Description != "" {
+		x.Description = o.Description
+	}
+	if o.Rating != 0 {
+		x.Rating = o.Rating
+	}
+	if o.Label != "" {
+		x.Label = o.Label
+	}
+	if !o.Created.IsZero() {
+		x.Created = o.Created
+	}
+	if o.HasGPS {
+		x.HasGPS, x.Lat, x.Lon = true, o.Lat, o.Lon
+	}
+}
+
+// applyXMP records x's metadata on the pic.
+func (p *Pic) applyXMP(x *XMP) error {
+	if x.Description != "" {
+		if err := p.SetNotes(x.Description); err != nil {
+			return err
+		}
+	}
+	if len(x.Subject) > 0 {
+		if err := p.SetTags(x.Subject); err != nil {
+			return err
+		}
+	}
+	if x.Rating > 0 {
+		if err := p.SetRating(x.Rating); err != nil {
+			return err
+		}
+	} else if x.Rating < 0 {
+		if err := p.SetFlag(Rejected); err != nil {
+			return err
+		}
+	}
+	for _, l := range Labels {
+		if x.Label == l {
+			if err := p.SetLabel(l); err != nil {
+				return err
+			}
+		}
+	}
+	if x.HasGPS {
+		return p.SetLocation(x.Lat, x.Lon)
+	}
+	return nil
+}
+
+// XMP builds an XMP packet from the pic's library metadata.
+func (p *Pic) XMP() (*XMP, error) {
+	x := &XMP{Rating: p.Rating, Label: p.Label}
+	if p.Flag == Rejected {
+		x.Rating = -1
+	}
+	if p.Precision == Exact && !p.Taken.IsZero() {
+		x.Created = p.Taken
+	}
+
+	var err error
+	if x.Description, err = p.GetNotes(); err != nil {
+		return nil, err
+	}
+	if x.Subject, err = p.Tags(); err != nil {
+		return nil, err
+	}
+	if x.Lat, x.Lon, x.HasGPS, err = p.Location(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
@@ -0,0 +1,235 @@
+package piclib
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLib returns a library in a new temporary directory along with a
+// function removing it.
+func testLib(t *testing.T) (*Lib, func()) {
+	dir, err := ioutil.TempDir("", "piclib-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := Open(filepath.Join(dir, "lib"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return l, func() { os.RemoveAll(dir) }
+}
+
+// writeJPEG writes a small jpeg with the given fill color to path.
+func writeJPEG(t *testing.T, path string, c color.Color) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var testXMP = &XMP{
+	Subject:     []string{"beach", "family & friends"},
+	Description: "Sunset at <the> pier",
+	Rating:      4,
+	Label:       "red",
+	Created:     time.Date(2019, 7, 4, 18, 30, 15, 0, time.Local),
+	HasGPS:      true,
+	Lat:         36.9741,
+	Lon:         -122.0308,
+}
+
+func compareXMP(t *testing.T, got, want *XMP) {
+	if got == nil {
+		t.Fatal("no xmp metadata read")
+	}
+	if !reflect.DeepEqual(got.Subject, want.Subject) {
+		t.Errorf("subject: got %q, want %q", got.Subject, want.Subject)
+	}
+	if got.Description != want.Description {
+		t.Errorf("description: got %q, want %q", got.Description, want.Description)
+	}
+	if got.Rating != want.Rating {
+		t.Errorf("rating: got %v, want %v", got.Rating, want.Rating)
+	}
+	if got.Label != want.Label {
+		t.Errorf("label: got %q, want %q", got.Label, want.Label)
+	}
+	if !got.Created.Equal(want.Created) {
+		t.Errorf("created: got %v, want %v", got.Created, want.Created)
+	}
+	if got.HasGPS != want.HasGPS || math.Abs(got.Lat-want.Lat) > 1e-6 || math.Abs(got.Lon-want.Lon) > 1e-6 {
+		t.Errorf("location: got %v %v,%v, want %v %v,%v", got.HasGPS, got.Lat, got.Lon, want.HasGPS, want.Lat, want.Lon)
+	}
+}
+
+func TestXMPRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := testXMP.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	x, err := ParseXMP(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareXMP(t, x, testXMP)
+
+	// embedded in the middle of other data as in a jpeg APP1 segment
+	data := append([]byte("\xff\xd8\xff\xe1junk"), buf.Bytes()...)
+	data = append(data, "\xff\xd9"...)
+	x, err = EmbeddedXMP(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareXMP(t, x, testXMP)
+}
+
+func TestSidecarImport(t *testing.T) {
+	l, done := testLib(t)
+	defer done()
+
+	path := filepath.Join(filepath.Dir(l.Path), "IMG_0001.jpg")
+	writeJPEG(t, path, color.RGBA{200, 100, 50, 255})
+	f, err := os.Create(path + ".xmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testXMP.WriteTo(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := l.AddFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err = l.Open(p.Id); err != nil {
+		t.Fatal(err)
+	}
+	if notes, _ := p.GetNotes(); notes != testXMP.Description {
+		t.Errorf("notes: got %q, want %q", notes, testXMP.Description)
+	}
+	if tags, _ := p.Tags(); !reflect.DeepEqual(tags, testXMP.Subject) {
+		t.Errorf("tags: got %q, want %q", tags, testXMP.Subject)
+	}
+	if p.Rating != testXMP.Rating || p.Label != testXMP.Label {
+		t.Errorf("rating/label: got %v/%v, want %v/%v", p.Rating, p.Label, testXMP.Rating, testXMP.Label)
+	}
+	if !p.Taken.Equal(testXMP.Created) {
+		t.Errorf("taken: got %v, want %v", p.Taken, testXMP.Created)
+	}
+
+	// write the library's metadata back out as a sidecar of a copy
+	cp := filepath.Join(filepath.Dir(l.Path), "copy.jpg")
+	writeJPEG(t, cp, color.Black)
+	x, err := p.XMP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err = os.Create(cp + ".xmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = x.WriteTo(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = os.Open(cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := readXMP(cp, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareXMP(t, got, testXMP)
+}
+
+func TestMalformedXMP(t *testing.T) {
+	l, done := testLib(t)
+	defer done()
+	var warnings []string
+	l.Warn = func(msg string) { warnings = append(warnings, msg) }
+
+	dir := filepath.Dir(l.Path)
+	side := filepath.Join(dir, "side.jpg")
+	writeJPEG(t, side, color.White)
+	if err := ioutil.WriteFile(side+".xmp", []byte("<x:xmpmeta><rdf:RDF></x:xmpmeta>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	writeJPEG(t, filepath.Join(dir, "tmp.jpg"), color.Gray{128})
+	data, err := ioutil.ReadFile(filepath.Join(dir, "tmp.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(data[:2])
+	buf.WriteString("<x:xmpmeta><rdf:RDF><broken></x:xmpmeta>")
+	buf.Write(data[2:])
+	embedded := filepath.Join(dir, "embedded.jpg")
+	if err := ioutil.WriteFile(embedded, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{side, embedded} {
+		warnings = nil
+		if _, err := l.AddFile(path); err != nil {
+			t.Errorf("%v: %v", path, err)
+		}
+		if len(warnings) != 1 || !strings.Contains(warnings[0], "invalid") {
+			t.Errorf("%v: got warnings %q, want one about invalid xmp", path, warnings)
+		}
+	}
+}
+
+func TestXMPOutOfSpec(t *testing.T) {
+	l, done := testLib(t)
+	defer done()
+
+	path := filepath.Join(filepath.Dir(l.Path), "IMG_0002.jpg")
+	writeJPEG(t, path, color.White)
+	sidecar := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:exif="http://ns.adobe.com/exif/1.0/"
+ xmp:Rating="6" exif:GPSLatitude="36,58.446N" exif:GPSLongitude="bogus"/>
+</rdf:RDF></x:xmpmeta>`
+	if err := ioutil.WriteFile(path+".xmp", []byte(sidecar), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := l.AddFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Rating != 5 {
+		t.Errorf("got rating %v, want out of spec rating clamped to 5", p.Rating)
+	}
+	if _, _, ok, err := p.Location(); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("location set from a latitude without a longitude")
+	}
+}